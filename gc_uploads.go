@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/janphilippgutt/casproject/internal/config"
+	"github.com/janphilippgutt/casproject/internal/db"
+	"github.com/janphilippgutt/casproject/internal/repository"
+	"github.com/janphilippgutt/casproject/internal/uploadgc"
+)
+
+// gcUploads runs the gc-uploads subcommand: it deletes the uploaded files
+// no project refers to, or with -dry-run only lists them.
+func gcUploads(cfg config.Config, args []string) error {
+	flags := flag.NewFlagSet("gc-uploads", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "list orphaned files without deleting them")
+	grace := flags.Duration("grace", cfg.Storage.GCGrace, "only delete files older than this")
+	flags.Parse(args)
+
+	dbPool, err := db.Connect()
+	if err != nil {
+		return fmt.Errorf("database connection failed: %w", err)
+	}
+	defer dbPool.Close()
+
+	store, err := openStore(cfg.Storage)
+	if err != nil {
+		return fmt.Errorf("object storage unavailable: %w", err)
+	}
+
+	collector := &uploadgc.Collector{
+		Store: store,
+		Repo:  &repository.ProjectRepository{DB: dbPool},
+		Grace: *grace,
+	}
+	res, err := collector.Run(context.Background(), *dryRun)
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		for _, key := range res.Orphans {
+			fmt.Println("would delete", key)
+		}
+		fmt.Printf("%d files scanned, %d orphaned (dry run, nothing deleted)\n", res.Scanned, len(res.Orphans))
+		return nil
+	}
+
+	fmt.Printf("%d files scanned, %d orphaned, %d deleted\n", res.Scanned, len(res.Orphans), len(res.Orphans)-res.Failed)
+	if res.Failed > 0 {
+		return fmt.Errorf("%d files could not be deleted", res.Failed)
+	}
+	return nil
+}
@@ -1,28 +1,57 @@
 package handlers
 
 import (
+	"context"
 	"html/template"
 	"log"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-chi/chi/v5"
+	"github.com/janphilippgutt/casproject/internal/auth"
 	"github.com/janphilippgutt/casproject/internal/models"
 	"github.com/janphilippgutt/casproject/internal/repository"
+	"github.com/janphilippgutt/casproject/internal/storage"
 )
 
 type AdminData struct {
 	BasePageData
 	Email string
+	Roles []string
+	Flash string
+	Error string
 }
 
 type AdminProjectsData struct {
 	BasePageData
-	Pending  []models.Project
-	Approved []models.Project
+	// Tab is the list shown: pending, approved, scheduled or rejected.
+	Tab        string
+	Projects   []models.Project
+	Pagination Pagination
+	Flash      string
+	Error      string
+	// Query filters the pending queue; Snippets holds the highlighted
+	// excerpts of the matches.
+	Query    string
+	Snippets map[int]template.HTML
+}
+
+// adminProjectTabs maps each tab of the moderation page to its list and
+// default order. The queues default to oldest first, so whatever has
+// waited longest comes up first.
+var adminProjectTabs = map[string]struct {
+	list func(*repository.ProjectRepository, context.Context, repository.PageRequest) (repository.ProjectPage, error)
+	sort repository.Sort
+}{
+	"pending":   {(*repository.ProjectRepository).ListPending, repository.SortOldest},
+	"approved":  {(*repository.ProjectRepository).ListApproved, repository.SortNewest},
+	"scheduled": {(*repository.ProjectRepository).ListScheduled, repository.SortOldest},
+	"rejected":  {(*repository.ProjectRepository).ListRejected, repository.SortNewest},
 }
 
 func Admin(t *template.Template, sess *scs.SessionManager) http.HandlerFunc {
@@ -36,9 +65,9 @@ func Admin(t *template.Template, sess *scs.SessionManager) http.HandlerFunc {
 			return
 		}
 
-		// Authorization check: only allow users with role == "admin"
+		// Authorization check: the dashboard hosts user management tools
 		role := sess.GetString(r.Context(), "role")
-		if role != "admin" {
+		if !auth.HasPermission(role, auth.PermUsersManage) {
 			// Authenticated but not authorized --> 403 Forbidden
 			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
@@ -50,6 +79,9 @@ func Admin(t *template.Template, sess *scs.SessionManager) http.HandlerFunc {
 		data := AdminData{
 			BasePageData: NewBaseData(r.Context(), sess),
 			Email:        email,
+			Roles:        models.Roles,
+			Flash:        sess.PopString(r.Context(), "flash"),
+			Error:        sess.PopString(r.Context(), "flash_error"),
 		}
 		if err := t.ExecuteTemplate(w, "admin", data); err != nil {
 			log.Println("admin template error:", err)
@@ -61,28 +93,44 @@ func Admin(t *template.Template, sess *scs.SessionManager) http.HandlerFunc {
 	}
 }
 
-func ListUnapprovedProjects(t *template.Template, repo *repository.ProjectRepository, sess *scs.SessionManager) http.HandlerFunc {
+func ListUnapprovedProjects(t *template.Template, repo *repository.ProjectRepository, sess *scs.SessionManager, pageSize int) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		pending, err := repo.ListUnapproved(r.Context())
-		if err != nil {
-			log.Println("list unapproved projects error:", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
+		data := AdminProjectsData{
+			BasePageData: NewBaseData(r.Context(), sess),
+			Tab:          r.URL.Query().Get("status"),
+			Query:        searchQuery(r.URL.Query().Get("q")),
 		}
 
-		approved, err := repo.ListApproved(r.Context())
-		if err != nil {
-			log.Println("list approved projects error:", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
+		tab, ok := adminProjectTabs[data.Tab]
+		if !ok {
+			data.Tab = "pending"
+			tab = adminProjectTabs[data.Tab]
 		}
 
-		data := AdminProjectsData{
-			BasePageData: NewBaseData(r.Context(), sess),
-			Pending:      pending,
-			Approved:     approved,
+		// Search covers the pending queue only and is ranked, not paged.
+		if data.Query != "" && data.Tab == "pending" {
+			results, err := repo.Search(r.Context(), data.Query, models.StatusPending)
+			if err != nil {
+				log.Println("search pending projects error:", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			data.Projects, data.Snippets = splitSearchResults(results)
+		} else {
+			data.Query = ""
+			req := pageRequest(r, pageSize, tab.sort)
+			page, err := tab.list(repo, r.Context(), req)
+			if err != nil {
+				writeListError(w, "list "+data.Tab+" projects error:", err)
+				return
+			}
+			data.Projects = page.Projects
+			data.Pagination = newPagination(r, req, page)
 		}
 
+		data.Flash = sess.PopString(r.Context(), "flash")
+		data.Error = sess.PopString(r.Context(), "flash_error")
+
 		if err := t.ExecuteTemplate(w, "admin_projects", data); err != nil {
 			log.Println("template execute error:", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -90,8 +138,18 @@ func ListUnapprovedProjects(t *template.Template, repo *repository.ProjectReposi
 	}
 }
 
-func ApproveProject(repo *repository.ProjectRepository) http.HandlerFunc {
+// publishAtLayout is the value format of an <input type="datetime-local">.
+const publishAtLayout = "2006-01-02T15:04"
+
+// DateTimeLayout is how pages show a time: in the app's zone, named, since
+// moderators may be elsewhere.
+const DateTimeLayout = "2006-01-02 15:04 MST"
+
+// ApproveProject approves a project. An optional publish_at form value,
+// a wall time in loc, schedules publication instead of publishing now.
+func ApproveProject(repo *repository.ProjectRepository, sess *scs.SessionManager, loc *time.Location) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
 		idParam := chi.URLParam(r, "id")
 
 		projectID, err := strconv.Atoi(idParam)
@@ -100,35 +158,117 @@ func ApproveProject(repo *repository.ProjectRepository) http.HandlerFunc {
 			return
 		}
 
-		if err := repo.Approve(r.Context(), projectID); err != nil {
+		var publishAt *time.Time
+		if v := strings.TrimSpace(r.FormValue("publish_at")); v != "" {
+			t, err := time.ParseInLocation(publishAtLayout, v, loc)
+			if err != nil {
+				sess.Put(ctx, "flash_error", "Invalid publication date")
+				http.Redirect(w, r, "/admin/projects", http.StatusSeeOther)
+				return
+			}
+			// A time in the past just means "now".
+			if t.After(time.Now()) {
+				publishAt = &t
+			}
+		}
+
+		if err := repo.Approve(ctx, projectID, publishAt); err != nil {
 			log.Println("approve project error:", err)
 			http.Error(w, "Failed to approve project", http.StatusInternalServerError)
 			return
 		}
 
+		attrs := []any{
+			"event.category", "admin",
+			"event.type", "approve",
+			"user.id", sess.GetString(ctx, "user_email"),
+			"project.id", projectID,
+		}
+		if publishAt != nil {
+			attrs = append(attrs, "project.publish_at", publishAt.UTC())
+			sess.Put(ctx, "flash", "Project approved. It will be published on "+publishAt.Format(DateTimeLayout)+".")
+		}
+		slog.Info("project approved", attrs...)
+
 		// PRG pattern
 		http.Redirect(w, r, "/admin/projects", http.StatusSeeOther)
 	}
 }
 
+// maxRejectionReason bounds the feedback a moderator can leave.
+const maxRejectionReason = 1000
+
+// RejectProject moves a pending project to rejected. A reason is required
+// since it is the only feedback the author gets.
+func RejectProject(repo *repository.ProjectRepository, sess *scs.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		reason := strings.TrimSpace(r.FormValue("reason"))
+		if reason == "" {
+			sess.Put(ctx, "flash_error", "Please give the author a reason for the rejection")
+			http.Redirect(w, r, "/admin/projects", http.StatusSeeOther)
+			return
+		}
+		if len(reason) > maxRejectionReason {
+			sess.Put(ctx, "flash_error", "The rejection reason must be at most 1000 characters")
+			http.Redirect(w, r, "/admin/projects", http.StatusSeeOther)
+			return
+		}
+
+		if err := repo.Reject(ctx, id, reason); err != nil {
+			slog.Error(
+				"failed to reject project",
+				"event.category", "admin",
+				"event.type", "reject",
+				"project.id", id,
+				"error", err,
+			)
+			http.Error(w, "Could not reject project", http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info(
+			"project rejected",
+			"event.category", "admin",
+			"event.type", "reject",
+			"user.id", sess.GetString(ctx, "user_email"),
+			"project.id", id,
+		)
+
+		sess.Put(ctx, "flash", "Project rejected. The author can see your reason.")
+		http.Redirect(w, r, "/admin/projects", http.StatusSeeOther)
+	}
+}
+
 func AdminArchivedProjects(
 	t *template.Template,
 	repo *repository.ProjectRepository,
 	sess *scs.SessionManager,
+	pageSize int,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		projects, err := repo.ListArchived(r.Context())
+		req := pageRequest(r, pageSize, repository.SortNewest)
+		page, err := repo.ListArchived(r.Context(), req)
 		if err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			writeListError(w, "list archived projects error:", err)
 			return
 		}
 
 		data := struct {
 			BasePageData
-			Archived []models.Project
+			Archived   []models.Project
+			Pagination Pagination
 		}{
 			BasePageData: NewBaseData(r.Context(), sess),
-			Archived:     projects,
+			Archived:     page.Projects,
+			Pagination:   newPagination(r, req, page),
 		}
 
 		t.ExecuteTemplate(w, "admin_archived_projects", data)
@@ -146,7 +286,8 @@ func RestoreProject(repo *repository.ProjectRepository, sess *scs.SessionManager
 			return
 		}
 
-		if err := repo.Restore(ctx, id); err != nil {
+		status, err := repo.Restore(ctx, id)
+		if err != nil {
 			slog.Error(
 				"failed to restore project",
 				"event.category", "admin",
@@ -165,6 +306,7 @@ func RestoreProject(repo *repository.ProjectRepository, sess *scs.SessionManager
 			"event.type", "restore",
 			"user.id", userEmail,
 			"project.id", id,
+			"project.status", string(status),
 		)
 
 		http.Redirect(w, r, "/admin/projects", http.StatusSeeOther)
@@ -173,6 +315,7 @@ func RestoreProject(repo *repository.ProjectRepository, sess *scs.SessionManager
 
 func DeleteProjectForever(
 	repo *repository.ProjectRepository,
+	store storage.Store,
 	sess *scs.SessionManager,
 ) http.HandlerFunc {
 
@@ -186,7 +329,8 @@ func DeleteProjectForever(
 			return
 		}
 
-		if err := repo.DeleteForever(ctx, id); err != nil {
+		keys, err := repo.DeleteForever(ctx, id)
+		if err != nil {
 			slog.Error(
 				"failed to permanently delete project",
 				"event.category", "admin",
@@ -197,6 +341,7 @@ func DeleteProjectForever(
 			http.Error(w, "Could not delete project", http.StatusInternalServerError)
 			return
 		}
+		removeUploads(ctx, store, keys)
 
 		slog.Info(
 			"project permanently deleted",
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"html/template"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/janphilippgutt/casproject/internal/auth"
+	"github.com/janphilippgutt/casproject/internal/models"
+	"github.com/janphilippgutt/casproject/internal/repository"
+)
+
+type AdminUsersData struct {
+	BasePageData
+	Users []models.User
+	Query string
+	Roles []string
+	Flash string
+	Error string
+}
+
+func AdminUsers(t *template.Template, repo *repository.UserRepository, sess *scs.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+		users, err := repo.List(ctx, query)
+		if err != nil {
+			log.Println("list users error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		data := AdminUsersData{
+			BasePageData: NewBaseData(ctx, sess),
+			Users:        users,
+			Query:        query,
+			Roles:        models.Roles,
+			Flash:        sess.PopString(ctx, "flash"),
+			Error:        sess.PopString(ctx, "flash_error"),
+		}
+
+		if err := t.ExecuteTemplate(w, "admin_users", data); err != nil {
+			log.Println("template execute error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+	}
+}
+
+func ChangeUserRole(repo *repository.UserRepository, users *auth.UserCache, sess *scs.SessionManager) http.HandlerFunc {
+	return userAction(repo, users, sess, "role_change", func(ctx context.Context, r *http.Request, user *models.User) (string, error) {
+		role := r.FormValue("role")
+		if err := repo.SetRole(ctx, user.ID, role); err != nil {
+			return "", err
+		}
+		return user.Email + " is now " + role, nil
+	})
+}
+
+func DeactivateUser(repo *repository.UserRepository, users *auth.UserCache, sess *scs.SessionManager) http.HandlerFunc {
+	return userAction(repo, users, sess, "deactivate", func(ctx context.Context, r *http.Request, user *models.User) (string, error) {
+		// Also revokes the user's sessions, so reactivating the account
+		// doesn't bring them back.
+		if err := repo.Deactivate(ctx, user.ID); err != nil {
+			return "", err
+		}
+		return user.Email + " has been deactivated and logged out", nil
+	})
+}
+
+func ReactivateUser(repo *repository.UserRepository, users *auth.UserCache, sess *scs.SessionManager) http.HandlerFunc {
+	return userAction(repo, users, sess, "reactivate", func(ctx context.Context, r *http.Request, user *models.User) (string, error) {
+		if err := repo.Reactivate(ctx, user.ID); err != nil {
+			return "", err
+		}
+		return user.Email + " has been reactivated", nil
+	})
+}
+
+func LogoutUserEverywhere(repo *repository.UserRepository, users *auth.UserCache, sess *scs.SessionManager) http.HandlerFunc {
+	return userAction(repo, users, sess, "logout_everywhere", func(ctx context.Context, r *http.Request, user *models.User) (string, error) {
+		if err := repo.RevokeSessions(ctx, user.ID); err != nil {
+			return "", err
+		}
+		return user.Email + " has been logged out everywhere", nil
+	})
+}
+
+// userAction wraps the shared parts of the /admin/users/{id}/... POST
+// handlers: loading the target user, refusing actions on the acting admin's
+// own account, dropping the cached user so live sessions see the change,
+// audit logging and redirecting back with a flash message.
+func userAction(
+	repo *repository.UserRepository,
+	users *auth.UserCache,
+	sess *scs.SessionManager,
+	eventType string,
+	action func(ctx context.Context, r *http.Request, user *models.User) (string, error),
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		back := "/admin/users"
+		if q := r.FormValue("q"); q != "" {
+			back += "?q=" + url.QueryEscape(q)
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		user, err := repo.GetByID(ctx, id)
+		if err != nil {
+			log.Println("get user error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		actor := sess.GetString(ctx, "user_email")
+		if user.Email == actor {
+			sess.Put(ctx, "flash_error", "You cannot change your own account here")
+			http.Redirect(w, r, back, http.StatusSeeOther)
+			return
+		}
+
+		msg, err := action(ctx, r, user)
+		users.Invalidate(user.Email)
+		if err != nil {
+			slog.Error(
+				"user management action failed",
+				"event.category", "admin",
+				"event.type", eventType,
+				"user.id", actor,
+				"target.user.id", user.Email,
+				"error", err,
+			)
+			sess.Put(ctx, "flash_error", "Could not update "+user.Email)
+			http.Redirect(w, r, back, http.StatusSeeOther)
+			return
+		}
+
+		slog.Info(
+			"user management action",
+			"event.category", "admin",
+			"event.type", eventType,
+			"user.id", actor,
+			"target.user.id", user.Email,
+		)
+
+		sess.Put(ctx, "flash", msg)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+	}
+}
@@ -5,13 +5,29 @@ import (
 	"log/slog"
 
 	"github.com/alexedwards/scs/v2"
+
+	"github.com/janphilippgutt/casproject/internal/auth"
+	"github.com/janphilippgutt/casproject/middleware"
 )
 
 // shared view-model that carries user-related UI state
 type BasePageData struct {
 	IsAuthenticated bool
-	IsAdmin         bool
+	Role            string
 	UserEmail       string
+	CSRFToken       string
+}
+
+// Can reports whether the current user holds the named permission,
+// e.g. {{ if .Can "projects.approve" }} in templates.
+func (b BasePageData) Can(permission string) bool {
+	return auth.HasPermission(b.Role, auth.Permission(permission))
+}
+
+// Privileged reports whether the current user's role grants any
+// permission, which the header shows as a role badge.
+func (b BasePageData) Privileged() bool {
+	return auth.HasAnyPermission(b.Role)
 }
 
 // derive user state from the request context via the session manager.
@@ -25,9 +41,23 @@ func NewBaseData(ctx context.Context, sess *scs.SessionManager) BasePageData {
 		"role", role,
 	)
 
-	return BasePageData{
+	data := BasePageData{
 		IsAuthenticated: email != "",
-		IsAdmin:         role == "admin",
+		Role:            role,
 		UserEmail:       email,
 	}
+	// Logged-in pages carry the logout form. Anonymous visitors get a token
+	// only from NewFormBaseData, so browsing doesn't start a session.
+	if data.IsAuthenticated {
+		data.CSRFToken = middleware.CSRFToken(ctx, sess)
+	}
+	return data
+}
+
+// NewFormBaseData is NewBaseData for pages with a POST form that anonymous
+// visitors can see, such as login and registration.
+func NewFormBaseData(ctx context.Context, sess *scs.SessionManager) BasePageData {
+	data := NewBaseData(ctx, sess)
+	data.CSRFToken = middleware.CSRFToken(ctx, sess)
+	return data
 }
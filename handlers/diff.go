@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/janphilippgutt/casproject/internal/models"
+)
+
+// FieldDiff compares one field of two project revisions.
+type FieldDiff struct {
+	Field   string
+	Old     string
+	New     string
+	Changed bool
+	// Lines is a line-by-line diff for multi-line fields such as the description.
+	Lines []DiffLine
+	// OldImages and NewImages are the galleries compared by the Images field.
+	OldImages []models.ProjectImage
+	NewImages []models.ProjectImage
+}
+
+type DiffLine struct {
+	Op   string // "=" unchanged, "-" removed, "+" added
+	Text string
+}
+
+func diffRevisions(base, compare *models.ProjectRevision) []FieldDiff {
+	diffs := []FieldDiff{
+		{Field: "Title", Old: base.Title, New: compare.Title},
+		{Field: "Description", Old: base.Description, New: compare.Description},
+		{Field: "Tags", Old: strings.Join(base.Tags, ", "), New: strings.Join(compare.Tags, ", ")},
+	}
+
+	for i := range diffs {
+		diffs[i].Changed = diffs[i].Old != diffs[i].New
+	}
+	if diffs[1].Changed {
+		diffs[1].Lines = diffLines(base.Description, compare.Description)
+	}
+
+	// The gallery changes with any image, alt text, caption or order.
+	diffs = append(diffs, FieldDiff{
+		Field:     "Images",
+		OldImages: base.Images,
+		NewImages: compare.Images,
+		Changed: !slices.EqualFunc(base.Images, compare.Images, func(a, b models.ProjectImage) bool {
+			return a.Path == b.Path && a.AltText == b.AltText && a.Caption == b.Caption
+		}),
+	})
+
+	return diffs
+}
+
+// diffLines returns a minimal line diff of a and b based on their longest
+// common subsequence. Descriptions are short, so the quadratic table is fine.
+func diffLines(a, b string) []DiffLine {
+	x := strings.Split(a, "\n")
+	y := strings.Split(b, "\n")
+
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []DiffLine
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			lines = append(lines, DiffLine{"=", x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, DiffLine{"-", x[i]})
+			i++
+		default:
+			lines = append(lines, DiffLine{"+", y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		lines = append(lines, DiffLine{"-", x[i]})
+	}
+	for ; j < len(y); j++ {
+		lines = append(lines, DiffLine{"+", y[j]})
+	}
+
+	return lines
+}
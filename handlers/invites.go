@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/janphilippgutt/casproject/internal/auth"
+	"github.com/janphilippgutt/casproject/internal/db"
+	"github.com/janphilippgutt/casproject/internal/models"
+)
+
+// inviteTTL is how long an invitation link stays valid.
+const inviteTTL = 7 * 24 * time.Hour
+
+// CreateInvite emails a magic link that creates the account with the chosen
+// role on first use. Results are reported on /admin via flash messages.
+func CreateInvite(sess *scs.SessionManager, pool *pgxpool.Pool, links *auth.MagicLinks) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		addr, err := netmail.ParseAddress(strings.TrimSpace(r.FormValue("email")))
+		if err != nil {
+			sess.Put(ctx, "flash_error", "Please enter a valid email address")
+			http.Redirect(w, r, "/admin", http.StatusSeeOther)
+			return
+		}
+		email := addr.Address
+
+		role := r.FormValue("role")
+		if !models.IsValidRole(role) {
+			sess.Put(ctx, "flash_error", "Please choose a valid role")
+			http.Redirect(w, r, "/admin", http.StatusSeeOther)
+			return
+		}
+
+		_, err = db.GetUserByEmail(ctx, pool, email)
+		if err == nil {
+			sess.Put(ctx, "flash_error", email+" already has an account")
+			http.Redirect(w, r, "/admin", http.StatusSeeOther)
+			return
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			log.Println("invite user lookup error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		lt := auth.LoginToken{Email: email, Purpose: auth.PurposeInvite, Role: role}
+		if err := links.Send(ctx, lt, inviteTTL, "invite", "You have been invited to ProjectHub"); err != nil {
+			slog.Error(
+				"failed to send invite",
+				"event.category", "admin",
+				"event.type", "invite",
+				"invitee.email", email,
+				"error", err,
+			)
+			sess.Put(ctx, "flash_error", "Could not send the invitation, please try again")
+			http.Redirect(w, r, "/admin", http.StatusSeeOther)
+			return
+		}
+
+		slog.Info(
+			"invite sent",
+			"event.category", "admin",
+			"event.type", "invite",
+			"user.id", sess.GetString(ctx, "user_email"),
+			"invitee.email", email,
+			"invitee.role", role,
+		)
+
+		sess.Put(ctx, "flash", "Invitation sent to "+email)
+		http.Redirect(w, r, "/admin", http.StatusSeeOther)
+	}
+}
@@ -1,14 +1,16 @@
 package handlers
 
 import (
+	"errors"
 	"html/template"
 	"log"
 	"log/slog"
 	"net/http"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/janphilippgutt/casproject/internal/auth"
@@ -20,22 +22,35 @@ type LoginData struct {
 	Email string
 	Next  string
 	Error string
+	Sent  bool
+	// RegistrationOpen shows the link to /register.
+	RegistrationOpen bool
 }
 
-func Login(t *template.Template, sess *scs.SessionManager, pool *pgxpool.Pool, tokenStore *auth.TokenStore) http.HandlerFunc {
+// loginLinkTTL is how long an emailed login link stays valid.
+const loginLinkTTL = 15 * time.Minute
+
+func Login(
+	t *template.Template,
+	sess *scs.SessionManager,
+	pool *pgxpool.Pool,
+	links *auth.MagicLinks,
+	registrationOpen bool,
+) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		switch r.Method {
 
 		case http.MethodGet:
-			// Read ?next=... from URL (if any)
-			next := r.URL.Query().Get("next")
+			// Read ?next=... from URL (if any); anything but a local path is dropped
+			next := safeNext(r.URL.Query().Get("next"))
 
 			data := LoginData{
-				BasePageData: NewBaseData(r.Context(), sess),
-				Email:        "",
-				Next:         next,
-				Error:        "",
+				BasePageData:     NewFormBaseData(r.Context(), sess),
+				Email:            "",
+				Next:             next,
+				Error:            "",
+				RegistrationOpen: registrationOpen,
 			}
 
 			if err := t.ExecuteTemplate(w, "login", data); err != nil {
@@ -49,15 +64,16 @@ func Login(t *template.Template, sess *scs.SessionManager, pool *pgxpool.Pool, t
 
 			ctx := r.Context()
 
-			email := r.FormValue("email")
-			next := r.FormValue("next") // comes from hidden form field
+			email := strings.TrimSpace(r.FormValue("email"))
+			next := safeNext(r.FormValue("next")) // comes from hidden form field
 
 			if email == "" {
 				data := LoginData{
-					BasePageData: NewBaseData(r.Context(), sess),
-					Email:        "",
-					Next:         next,
-					Error:        "Email is required",
+					BasePageData:     NewFormBaseData(r.Context(), sess),
+					Email:            "",
+					Next:             next,
+					Error:            "Email is required",
+					RegistrationOpen: registrationOpen,
 				}
 				if err := t.ExecuteTemplate(w, "login", data); err != nil {
 					log.Println("template execute error:", err)
@@ -69,8 +85,11 @@ func Login(t *template.Template, sess *scs.SessionManager, pool *pgxpool.Pool, t
 			// Look up user in DB
 			user, err := db.GetUserByEmail(ctx, pool, email)
 			if err != nil {
-				// If no rows, show friendly error. For any other DB error, log and show generic error.
-				// pgx returns pgx.ErrNoRows for not found (use errors.Is check if needed).
+				if !errors.Is(err, pgx.ErrNoRows) {
+					log.Println("login user lookup error:", err)
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					return
+				}
 
 				slog.LogAttrs(
 					r.Context(),
@@ -81,46 +100,30 @@ func Login(t *template.Template, sess *scs.SessionManager, pool *pgxpool.Pool, t
 					slog.String("user.id", email),
 				)
 
-				data := LoginData{
-					BasePageData: NewBaseData(r.Context(), sess),
-					Email:        email,
-					Next:         next,
-					Error:        "No account found for that email.",
-				}
-				if err := t.ExecuteTemplate(w, "login", data); err != nil {
-					log.Println("template execute error:", err)
-					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-				}
+				// Same response as for a known address, so the form can't be
+				// used to find out who has an account.
+				renderLoginSent(w, r, t, sess, email)
 				return
 			}
 
-			token, err := auth.GenerateToken(32)
-			if err != nil {
-
+			if !user.Active() {
 				slog.LogAttrs(
 					r.Context(),
-					slog.LevelError,
-					"login failed: token generation error",
+					slog.LevelWarn,
+					"login failed: account deactivated",
 					slog.String("event.category", "auth"),
-					slog.String("event.type", "error"),
+					slog.String("event.type", "fail"),
 					slog.String("user.id", user.Email),
-					slog.Any("error", err),
 				)
 
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				renderLoginSent(w, r, t, sess, email)
 				return
 			}
 
-			tokenStore.Add(token, user.Email, 15*time.Minute)
-
-			port := os.Getenv("PORT")
-			if port == "" {
-				port = "8080"
-			}
-
-			// dev: print login link to terminal. In production send per email instead.
-			log.Println("Magic login link:")
-			log.Println("http://localhost:" + port + "/magic-login?token=" + token)
+			lt := auth.LoginToken{Email: user.Email, Purpose: auth.PurposeLogin, Next: next}
+			// Delivered in the background: waiting for the mail server, or
+			// failing when it is down, would tell known addresses apart.
+			links.SendAsync(ctx, lt, loginLinkTTL, "login", "Your ProjectHub login link")
 
 			slog.LogAttrs(
 				r.Context(),
@@ -132,7 +135,7 @@ func Login(t *template.Template, sess *scs.SessionManager, pool *pgxpool.Pool, t
 			)
 
 			// Show confirmation instead of logging user in
-			w.Write([]byte("Check your email for the login link (see server log)."))
+			renderLoginSent(w, r, t, sess, email)
 			return
 
 		default:
@@ -141,6 +144,27 @@ func Login(t *template.Template, sess *scs.SessionManager, pool *pgxpool.Pool, t
 	}
 }
 
+// LoginLinkSent renders the "check your inbox" confirmation for the posted
+// email. It is also served when the per-email rate limit trips, so a
+// throttled request looks exactly like a successful one.
+func LoginLinkSent(t *template.Template, sess *scs.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		renderLoginSent(w, r, t, sess, strings.TrimSpace(r.PostFormValue("email")))
+	}
+}
+
+func renderLoginSent(w http.ResponseWriter, r *http.Request, t *template.Template, sess *scs.SessionManager, email string) {
+	data := LoginData{
+		BasePageData: NewBaseData(r.Context(), sess),
+		Email:        email,
+		Sent:         true,
+	}
+	if err := t.ExecuteTemplate(w, "login", data); err != nil {
+		log.Println("template execute error:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
 func Logout(sess *scs.SessionManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -160,5 +184,3 @@ func Logout(sess *scs.SessionManager) http.HandlerFunc {
 		http.Redirect(w, r, "/projects", http.StatusSeeOther)
 	}
 }
-
-// Security note: for production, validate next so it only redirects to safe internal paths (avoid open redirect attacks).
@@ -2,19 +2,22 @@ package handlers
 
 import (
 	"log"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/janphilippgutt/casproject/internal/auth"
 	"github.com/janphilippgutt/casproject/internal/db"
+	"github.com/janphilippgutt/casproject/internal/models"
 )
 
 func MagicLogin(
 	sess *scs.SessionManager,
 	pool *pgxpool.Pool,
-	tokenStore *auth.TokenStore,
+	tokenStore auth.TokenStore,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		token := r.URL.Query().Get("token")
@@ -23,23 +26,77 @@ func MagicLogin(
 			return
 		}
 
-		email, ok := tokenStore.Use(token)
+		lt, ok, err := tokenStore.Use(r.Context(), token)
+		if err != nil {
+			log.Println("magic login token store error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
 		if !ok {
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
 			return
 		}
 
-		user, err := db.GetUserByEmail(r.Context(), pool, email)
+		var user *models.User
+		switch lt.Purpose {
+		case auth.PurposeInvite, auth.PurposeVerify:
+			// First use of an invite or verification link creates the account.
+			role := lt.Role
+			if !models.IsValidRole(role) {
+				role = models.RoleUser
+			}
+			user, err = db.CreateUserIfNotExists(r.Context(), pool, lt.Email, role)
+			if err == nil {
+				slog.InfoContext(
+					r.Context(),
+					"account activated",
+					"event.category", "auth",
+					"event.type", string(lt.Purpose),
+					"user.id", user.Email,
+					"user.role", user.Role,
+				)
+			}
+		default:
+			user, err = db.GetUserByEmail(r.Context(), pool, lt.Email)
+		}
 		if err != nil {
 			log.Println("magic login db error:", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
 
+		if !user.Active() {
+			slog.WarnContext(
+				r.Context(),
+				"magic login rejected: account deactivated",
+				"event.category", "auth",
+				"event.type", "fail",
+				"user.id", user.Email,
+			)
+			http.Error(w, "This account has been deactivated", http.StatusForbidden)
+			return
+		}
+
+		// A new session token on login, so one planted before it (session
+		// fixation) doesn't become an authenticated session.
+		if err := sess.RenewToken(r.Context()); err != nil {
+			log.Println("magic login session renew error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
 		sess.Put(r.Context(), "authenticated", true)
 		sess.Put(r.Context(), "user_email", user.Email)
 		sess.Put(r.Context(), "role", user.Role)
+		sess.Put(r.Context(), "logged_in_at", time.Now().UnixNano())
+
+		// next was validated when the link was issued; check again in case
+		// the stored value predates that or the store was tampered with.
+		next := safeNext(lt.Next)
+		if next == "" {
+			next = defaultAfterLogin
+		}
 
-		http.Redirect(w, r, "/projects/new", http.StatusSeeOther)
+		http.Redirect(w, r, next, http.StatusSeeOther)
 	}
 }
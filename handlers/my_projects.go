@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/alexedwards/scs/v2"
+
+	"github.com/janphilippgutt/casproject/internal/models"
+	"github.com/janphilippgutt/casproject/internal/repository"
+)
+
+type MyProjectsData struct {
+	BasePageData
+	Drafts   []models.Project
+	Pending  []models.Project
+	Rejected []models.Project
+	Approved []models.Project
+	Archived []models.Project
+	Flash    string
+	Error    string
+}
+
+// MyProjects lists the logged-in user's own submissions grouped by
+// moderation state.
+func MyProjects(t *template.Template, repo *repository.ProjectRepository, sess *scs.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		projects, err := repo.ListByAuthor(ctx, sess.GetString(ctx, "user_email"))
+		if err != nil {
+			log.Println("list own projects error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		data := MyProjectsData{
+			BasePageData: NewBaseData(ctx, sess),
+			Flash:        sess.PopString(ctx, "flash"),
+			Error:        sess.PopString(ctx, "flash_error"),
+		}
+
+		for _, p := range projects {
+			switch p.Status {
+			case models.StatusArchived:
+				data.Archived = append(data.Archived, p)
+			case models.StatusApproved:
+				data.Approved = append(data.Approved, p)
+			case models.StatusRejected:
+				data.Rejected = append(data.Rejected, p)
+			case models.StatusDraft:
+				data.Drafts = append(data.Drafts, p)
+			default:
+				data.Pending = append(data.Pending, p)
+			}
+		}
+
+		if err := t.ExecuteTemplate(w, "my_projects", data); err != nil {
+			log.Println("template execute error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+	}
+}
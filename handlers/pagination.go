@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/janphilippgutt/casproject/internal/repository"
+)
+
+// Pagination is what the "pagination" and "sort_links" templates need to
+// render page and sort links for a list.
+type Pagination struct {
+	NextURL string
+	PrevURL string
+	Sorts   []SortLink
+}
+
+type SortLink struct {
+	Label  string
+	URL    string
+	Active bool
+}
+
+var sortLabels = map[repository.Sort]string{
+	repository.SortNewest: "Newest",
+	repository.SortOldest: "Oldest",
+	repository.SortTitle:  "Title",
+}
+
+// pageRequest reads ?sort=, ?after= and ?before= into a page request of
+// size items. Lists without a sort parameter use fallback.
+func pageRequest(r *http.Request, size int, fallback repository.Sort) repository.PageRequest {
+	q := r.URL.Query()
+	req := repository.PageRequest{
+		Sort:  repository.ParseSort(q.Get("sort"), fallback),
+		Limit: size,
+		After: q.Get("after"),
+	}
+	if req.After == "" {
+		req.Before = q.Get("before")
+	}
+	return req
+}
+
+// newPagination builds page and sort links for page, keeping every other
+// query parameter (filters, tabs) of the current URL.
+func newPagination(r *http.Request, req repository.PageRequest, page repository.ProjectPage) Pagination {
+	link := func(set map[string]string) string {
+		q := r.URL.Query()
+		q.Del("after")
+		q.Del("before")
+		for k, v := range set {
+			q.Set(k, v)
+		}
+		return r.URL.Path + "?" + q.Encode()
+	}
+
+	var p Pagination
+	if page.Next != "" {
+		p.NextURL = link(map[string]string{"sort": string(req.Sort), "after": page.Next})
+	}
+	if page.Prev != "" {
+		p.PrevURL = link(map[string]string{"sort": string(req.Sort), "before": page.Prev})
+	}
+	for _, s := range repository.Sorts {
+		p.Sorts = append(p.Sorts, SortLink{
+			Label:  sortLabels[s],
+			URL:    link(map[string]string{"sort": string(s)}),
+			Active: s == req.Sort,
+		})
+	}
+	return p
+}
+
+// writeListError answers a failed list query: a tampered or stale cursor
+// is the client's fault, anything else is logged as a server error.
+func writeListError(w http.ResponseWriter, msg string, err error) {
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		http.Error(w, "Invalid page link", http.StatusBadRequest)
+		return
+	}
+	log.Println(msg, err)
+	http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+}
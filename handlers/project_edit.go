@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"html/template"
+	"log"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/janphilippgutt/casproject/internal/models"
+	"github.com/janphilippgutt/casproject/internal/repository"
+	"github.com/janphilippgutt/casproject/internal/storage"
+	"github.com/janphilippgutt/casproject/middleware"
+)
+
+type ProjectEditData struct {
+	BasePageData
+	Project     *models.Project
+	Title       string
+	Description string
+	CuratedTags []models.Tag
+	FreeTags    string
+	Flash       string
+	Error       string
+}
+
+// EditProject lets the author of a project change it. Any saved edit puts
+// the project back into the moderation queue and records which fields
+// changed so moderators can see what to review. Drafts can also be saved
+// as drafts again.
+func EditProject(t *template.Template, repo *repository.ProjectRepository, tags *repository.TagRepository, store storage.Store, sess *scs.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		project, err := repo.GetByID(ctx, id)
+		if err != nil {
+			log.Println("get project error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		// Someone else's or archived projects look the same as missing ones.
+		userEmail := sess.GetString(ctx, "user_email")
+		if project == nil || project.AuthorEmail != userEmail || project.Status == models.StatusArchived {
+			http.NotFound(w, r)
+			return
+		}
+
+		editURL := "/projects/" + strconv.Itoa(project.ID) + "/edit"
+
+		switch r.Method {
+
+		case http.MethodGet:
+			curated, err := tags.ListCurated(ctx)
+			if err != nil {
+				log.Println("list curated tags error:", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			data := ProjectEditData{
+				BasePageData: NewBaseData(ctx, sess),
+				Project:      project,
+				Title:        project.Title,
+				Description:  project.Description,
+				CuratedTags:  curated,
+				FreeTags:     freeTagNames(project),
+				Flash:        sess.PopString(ctx, "flash"),
+				Error:        sess.PopString(ctx, "flash_error"),
+			}
+			if err := t.ExecuteTemplate(w, "project_edit", data); err != nil {
+				log.Println("template execute error:", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			}
+
+		case http.MethodPost:
+			// Usually already parsed by the CSRF middleware under the same limit.
+			r.Body = http.MaxBytesReader(w, r.Body, middleware.MaxUploadFormBytes)
+			if err := r.ParseMultipartForm(middleware.MaxUploadFormBytes); err != nil {
+				http.Error(w, "Could not parse form", http.StatusBadRequest)
+				return
+			}
+
+			title := strings.TrimSpace(r.FormValue("title"))
+			description := strings.TrimSpace(r.FormValue("description"))
+			if title == "" || description == "" {
+				sess.Put(ctx, "flash_error", "Title and description are required")
+				http.Redirect(w, r, editURL, http.StatusSeeOther)
+				return
+			}
+			if problem := descriptionProblem(r, description); problem != "" {
+				sess.Put(ctx, "flash_error", problem)
+				http.Redirect(w, r, editURL, http.StatusSeeOther)
+				return
+			}
+
+			tagNames, problem := formTags(r)
+			if problem != "" {
+				sess.Put(ctx, "flash_error", problem)
+				http.Redirect(w, r, editURL, http.StatusSeeOther)
+				return
+			}
+
+			images, problem := formGallery(r, project.Images)
+			if problem == "" && len(images)+uploadCount(r, "images") > models.MaxImagesPerProject {
+				problem = tooManyImages()
+			}
+			if problem != "" {
+				sess.Put(ctx, "flash_error", problem)
+				http.Redirect(w, r, editURL, http.StatusSeeOther)
+				return
+			}
+
+			uploads, err := saveImageUploads(r, store, "images")
+			if err != nil {
+				writeUploadError(w, err)
+				return
+			}
+			images = append(images, uploadedImages(uploads)...)
+
+			var changed []string
+			if title != project.Title {
+				changed = append(changed, "title")
+			}
+			if description != project.Description {
+				changed = append(changed, "description")
+			}
+			if !sameImages(project.Images, images) {
+				changed = append(changed, "images")
+			}
+			if !sameTags(project.Tags, tagNames) {
+				changed = append(changed, "tags")
+			}
+
+			// Only drafts can stay drafts; everything else goes back to review.
+			status := models.StatusPending
+			if project.Status == models.StatusDraft {
+				status = requestedStatus(r)
+			}
+
+			if len(changed) == 0 {
+				if status == models.StatusPending && project.Status == models.StatusDraft {
+					submitDraft(w, r, repo, sess, project.ID)
+					return
+				}
+				sess.Put(ctx, "flash", "Nothing changed")
+				http.Redirect(w, r, editURL, http.StatusSeeOther)
+				return
+			}
+
+			if err := repo.UpdateByAuthor(ctx, project.ID, userEmail, title, description, images, changed, status, tagNames); err != nil {
+				removeUploads(ctx, store, uploads)
+				slog.Error(
+					"update project failed",
+					"event.category", "project",
+					"event.type", "edit",
+					"user.id", userEmail,
+					"project.id", project.ID,
+					"error", err,
+				)
+				http.Error(w, "Failed to update project", http.StatusInternalServerError)
+				return
+			}
+			slog.Info(
+				"project edited",
+				"event.category", "project",
+				"event.type", "edit",
+				"user.id", userEmail,
+				"project.id", project.ID,
+				"project.previous_status", string(project.Status),
+				"project.status", string(status),
+				"project.changed_fields", changed,
+			)
+
+			switch {
+			case status == models.StatusDraft:
+				sess.Put(ctx, "flash", "Draft saved.")
+			case project.Status == models.StatusDraft:
+				sess.Put(ctx, "flash", "Your project was submitted and is waiting for review.")
+			default:
+				sess.Put(ctx, "flash", "Changes saved. Your project is waiting for review again.")
+			}
+			http.Redirect(w, r, editURL, http.StatusSeeOther)
+
+		default:
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// SubmitDraft sends one of the logged-in user's drafts to the moderation
+// queue as it is.
+func SubmitDraft(repo *repository.ProjectRepository, sess *scs.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		submitDraft(w, r, repo, sess, id)
+	}
+}
+
+func submitDraft(w http.ResponseWriter, r *http.Request, repo *repository.ProjectRepository, sess *scs.SessionManager, id int) {
+	ctx := r.Context()
+	userEmail := sess.GetString(ctx, "user_email")
+
+	if err := repo.Submit(ctx, id, userEmail); err != nil {
+		slog.Warn(
+			"submit draft failed",
+			"event.category", "project",
+			"event.type", "submit",
+			"user.id", userEmail,
+			"project.id", id,
+			"error", err,
+		)
+		sess.Put(ctx, "flash_error", "Only your own drafts can be submitted.")
+		http.Redirect(w, r, "/me/projects", http.StatusSeeOther)
+		return
+	}
+
+	slog.Info(
+		"draft submitted",
+		"event.category", "project",
+		"event.type", "submit",
+		"user.id", userEmail,
+		"project.id", id,
+	)
+
+	sess.Put(ctx, "flash", "Your project was submitted and is waiting for review.")
+	http.Redirect(w, r, "/me/projects", http.StatusSeeOther)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/janphilippgutt/casproject/internal/models"
+)
+
+// formGallery applies the gallery fields of the edit form to the current
+// images of a project: images marked for removal are dropped, alt texts
+// and captions are updated, and the rest is ordered by the positions the
+// author entered. A non-empty problem is a message for the author.
+func formGallery(r *http.Request, current []models.ProjectImage) (images []models.ProjectImage, problem string) {
+	moved := make(map[int]bool)
+	for _, img := range current {
+		key := strconv.Itoa(img.ID)
+		if r.FormValue("image_remove_"+key) != "" {
+			continue
+		}
+
+		img.AltText = strings.TrimSpace(r.FormValue("image_alt_" + key))
+		img.Caption = strings.TrimSpace(r.FormValue("image_caption_" + key))
+		if len(img.AltText) > models.MaxImageAltLength {
+			return nil, fmt.Sprintf("Alt texts must be at most %d characters", models.MaxImageAltLength)
+		}
+		if len(img.Caption) > models.MaxImageCaptionLength {
+			return nil, fmt.Sprintf("Captions must be at most %d characters", models.MaxImageCaptionLength)
+		}
+
+		if pos, err := strconv.Atoi(r.FormValue("image_position_" + key)); err == nil && pos != img.Position {
+			img.Position = pos
+			moved[img.ID] = true
+		}
+		images = append(images, img)
+	}
+
+	// On a tie the image the author moved goes first, so typing "1" for
+	// the third image makes it the cover.
+	slices.SortStableFunc(images, func(a, b models.ProjectImage) int {
+		if c := cmp.Compare(a.Position, b.Position); c != 0 {
+			return c
+		}
+		switch {
+		case moved[a.ID] && !moved[b.ID]:
+			return -1
+		case moved[b.ID] && !moved[a.ID]:
+			return 1
+		}
+		return 0
+	})
+
+	return images, ""
+}
+
+// uploadedImages turns freshly stored uploads into gallery entries.
+func uploadedImages(keys []string) []models.ProjectImage {
+	images := make([]models.ProjectImage, len(keys))
+	for i, key := range keys {
+		images[i] = models.ProjectImage{Path: key, HasVariants: true}
+	}
+	return images
+}
+
+// sameImages reports whether images is current unchanged: the same
+// pictures in the same order with the same alt texts and captions.
+func sameImages(current, images []models.ProjectImage) bool {
+	return slices.EqualFunc(current, images, func(a, b models.ProjectImage) bool {
+		return a.ID == b.ID && a.AltText == b.AltText && a.Caption == b.Caption
+	})
+}
+
+// tooManyImages is the message for a gallery above the limit.
+func tooManyImages() string {
+	return fmt.Sprintf("A project can have at most %d images", models.MaxImagesPerProject)
+}
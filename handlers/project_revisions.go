@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/janphilippgutt/casproject/internal/models"
+	"github.com/janphilippgutt/casproject/internal/repository"
+)
+
+type ProjectRevisionsData struct {
+	BasePageData
+	Project   *models.Project
+	Revisions []models.ProjectRevision
+	Base      *models.ProjectRevision
+	Compare   *models.ProjectRevision
+	Diffs     []FieldDiff
+	// ApprovedRevisionID is 0 if the project was never approved.
+	ApprovedRevisionID int
+}
+
+// ProjectRevisions shows a project's revision history and a field-level
+// diff between two revisions. By default it compares the last approved
+// revision (or the first one) with the latest.
+func ProjectRevisions(t *template.Template, repo *repository.ProjectRepository, sess *scs.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		project, err := repo.GetByID(ctx, id)
+		if err != nil {
+			log.Println("get project error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if project == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		revisions, err := repo.ListRevisions(ctx, id)
+		if err != nil {
+			log.Println("list revisions error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if len(revisions) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+
+		approvedID := 0
+		if project.ApprovedRevisionID != nil {
+			approvedID = *project.ApprovedRevisionID
+		}
+
+		baseID := revisions[0].ID
+		if approvedID != 0 {
+			baseID = approvedID
+		}
+		compareID := revisions[len(revisions)-1].ID
+
+		if v, err := strconv.Atoi(r.URL.Query().Get("base")); err == nil {
+			baseID = v
+		}
+		if v, err := strconv.Atoi(r.URL.Query().Get("compare")); err == nil {
+			compareID = v
+		}
+
+		base, err := repo.GetRevision(ctx, id, baseID)
+		if err != nil {
+			log.Println("get revision error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		compare, err := repo.GetRevision(ctx, id, compareID)
+		if err != nil {
+			log.Println("get revision error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if base == nil || compare == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		data := ProjectRevisionsData{
+			BasePageData:       NewBaseData(ctx, sess),
+			Project:            project,
+			Revisions:          revisions,
+			Base:               base,
+			Compare:            compare,
+			Diffs:              diffRevisions(base, compare),
+			ApprovedRevisionID: approvedID,
+		}
+
+		if err := t.ExecuteTemplate(w, "admin_project_revisions", data); err != nil {
+			log.Println("template execute error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+	}
+}
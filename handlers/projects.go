@@ -3,20 +3,19 @@ package handlers
 import (
 	"fmt"
 	"html/template"
-	"io"
 	"log"
 	"log/slog"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-chi/chi/v5"
-	"github.com/google/uuid"
+	"github.com/janphilippgutt/casproject/internal/markdown"
 	"github.com/janphilippgutt/casproject/internal/models"
 	"github.com/janphilippgutt/casproject/internal/repository"
+	"github.com/janphilippgutt/casproject/internal/storage"
 	"github.com/janphilippgutt/casproject/middleware"
 )
 
@@ -28,11 +27,21 @@ type ProjectNewData struct {
 	Title       string
 	Description string
 	Error       string
+	// CuratedTags are offered as checkboxes next to the free tags input.
+	CuratedTags []models.Tag
 }
 
 type ProjectsPageData struct {
 	BasePageData
 	Projects []models.Project
+	// Query is the search box content; when set, Projects holds the
+	// search results and Snippets their highlighted excerpts.
+	Query    string
+	Snippets map[int]template.HTML
+	// Pagination is empty for search results, which are ranked instead.
+	Pagination Pagination
+	// Tag is set when the list is filtered to one tag.
+	Tag *models.Tag
 }
 
 type ProjectDetailPageData struct {
@@ -40,15 +49,23 @@ type ProjectDetailPageData struct {
 	Project *models.Project
 }
 
-func NewProject(t *template.Template, repo *repository.ProjectRepository, sess *scs.SessionManager) http.HandlerFunc {
+func NewProject(t *template.Template, repo *repository.ProjectRepository, tags *repository.TagRepository, store storage.Store, sess *scs.SessionManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 
 		case http.MethodGet:
+			curated, err := tags.ListCurated(r.Context())
+			if err != nil {
+				log.Println("list curated tags error:", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
 			data := ProjectNewData{
 				BasePageData: NewBaseData(r.Context(), sess),
 				// PopString reads and removes the message; empty string = no message = safe
-				Error: sess.PopString(r.Context(), "flash_error"),
+				Error:       sess.PopString(r.Context(), "flash_error"),
+				CuratedTags: curated,
 			}
 			if err := t.ExecuteTemplate(w, "base", data); err != nil {
 				log.Println("template execute error:", err)
@@ -58,88 +75,52 @@ func NewProject(t *template.Template, repo *repository.ProjectRepository, sess *
 
 		case http.MethodPost:
 
-			r.Body = http.MaxBytesReader(w, r.Body, 10<<20)
-			if err := r.ParseMultipartForm(10 << 20); err != nil {
+			// Usually already parsed by the CSRF middleware under the same limit.
+			r.Body = http.MaxBytesReader(w, r.Body, middleware.MaxUploadFormBytes)
+			if err := r.ParseMultipartForm(middleware.MaxUploadFormBytes); err != nil {
 				http.Error(w, "Could not parse form", http.StatusBadRequest)
 				return
 			}
 
 			title := strings.TrimSpace(r.FormValue("title"))
 			description := strings.TrimSpace(r.FormValue("description"))
-			if len(description) == 0 {
-				http.Error(w, "Description is required", http.StatusBadRequest)
+			authorEmail := sess.GetString(r.Context(), "user_email")
+
+			if title == "" || description == "" {
+				sess.Put(r.Context(), "flash_error", "Title and description are required")
+				http.Redirect(w, r, "/projects/new", http.StatusSeeOther)
+				return
+			}
+			if problem := descriptionProblem(r, description); problem != "" {
+				sess.Put(r.Context(), "flash_error", problem)
+				http.Redirect(w, r, "/projects/new", http.StatusSeeOther)
 				return
 			}
-			if len(description) > 1000 {
-				http.Error(w, "Description must be at most 800 characters", http.StatusBadRequest)
-
-				slog.Warn(
-					"project description too long",
-					"event.category", "validation",
-					"field", "description",
-					"length", len(description),
-				)
 
+			tagNames, problem := formTags(r)
+			if problem != "" {
+				sess.Put(r.Context(), "flash_error", problem)
+				http.Redirect(w, r, "/projects/new", http.StatusSeeOther)
 				return
 			}
-			authorEmail := sess.GetString(r.Context(), "email")
 
-			if title == "" || description == "" {
-				sess.Put(r.Context(), "flash_error", "Title and description are required")
+			if uploadCount(r, "images") > models.MaxImagesPerProject {
+				sess.Put(r.Context(), "flash_error", tooManyImages())
 				http.Redirect(w, r, "/projects/new", http.StatusSeeOther)
 				return
 			}
 
-			var imagePath string
-			file, header, err := r.FormFile("image")
+			imageKeys, err := saveImageUploads(r, store, "images")
 			if err != nil {
-				if err == http.ErrMissingFile {
-					imagePath = "" // optional file not provided
-				} else {
-					log.Println("upload error:", err)
-					http.Error(w, "Invalid file upload", http.StatusBadRequest)
-					return
-				}
-			} else {
-				defer file.Close()
-				if header.Filename != "" {
-					buf := make([]byte, 512)
-					if _, err := file.Read(buf); err != nil {
-						http.Error(w, "Invalid file", http.StatusBadRequest)
-						return
-					}
-
-					contentType := http.DetectContentType(buf)
-					if contentType != "image/jpeg" && contentType != "image/png" {
-						http.Error(w, "Only JPEG and PNG allowed", http.StatusBadRequest)
-						return
-					}
-
-					if _, err := file.Seek(0, 0); err != nil {
-						http.Error(w, "Invalid file stream", http.StatusBadRequest)
-						return
-					}
-
-					filename := generateImageFilename(header.Filename)
-					dstPath := filepath.Join("uploads", "projects", filename)
-					dst, err := os.Create(dstPath)
-					if err != nil {
-						http.Error(w, "Could not save image", http.StatusInternalServerError)
-						return
-					}
-					defer dst.Close()
-
-					if _, err := io.Copy(dst, file); err != nil {
-						http.Error(w, "Could not write image", http.StatusInternalServerError)
-						return
-					}
-
-					imagePath = "/uploads/projects/" + filename
-				}
+				writeUploadError(w, err)
+				return
 			}
 
+			status := requestedStatus(r)
+
 			// Insert project
-			if err := repo.Create(r.Context(), title, description, imagePath, authorEmail); err != nil {
+			if err := repo.Create(r.Context(), title, description, uploadedImages(imageKeys), authorEmail, status, tagNames); err != nil {
+				removeUploads(r.Context(), store, imageKeys)
 				slog.Error(
 					"create project failed",
 					"error", err,
@@ -155,9 +136,15 @@ func NewProject(t *template.Template, repo *repository.ProjectRepository, sess *
 				slog.String("request_id", middleware.RequestIDFromContext(r.Context())),
 				slog.String("title", title),
 				slog.String("user", authorEmail),
+				slog.String("project.status", string(status)),
 			)
 
-			http.Redirect(w, r, "/", http.StatusSeeOther)
+			if status == models.StatusDraft {
+				sess.Put(r.Context(), "flash", "Draft saved. Only you can see it until you submit it for review.")
+			} else {
+				sess.Put(r.Context(), "flash", "Thanks! Your project was submitted and is waiting for review.")
+			}
+			http.Redirect(w, r, "/me/projects", http.StatusSeeOther)
 
 		default:
 			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
@@ -165,20 +152,68 @@ func NewProject(t *template.Template, repo *repository.ProjectRepository, sess *
 	}
 }
 
-func ListProjects(t *template.Template, repo *repository.ProjectRepository, sess *scs.SessionManager) http.HandlerFunc {
+// ListProjects renders the public project list. It serves /projects,
+// optionally filtered with ?tag=<slug>, and the tag pages /tags/{slug}.
+func ListProjects(
+	t *template.Template,
+	repo *repository.ProjectRepository,
+	tags *repository.TagRepository,
+	sess *scs.SessionManager,
+	pageSize int,
+) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
-		projects, err := repo.ListApproved(ctx)
-		if err != nil {
-			log.Println("list projects error:", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
-
 		data := ProjectsPageData{
 			BasePageData: NewBaseData(r.Context(), sess),
-			Projects:     projects}
+			Query:        searchQuery(r.URL.Query().Get("q")),
+		}
+
+		slug := chi.URLParam(r, "slug")
+		if slug == "" {
+			slug = r.URL.Query().Get("tag")
+		}
+		if slug != "" {
+			tag, err := tags.GetBySlug(ctx, slug)
+			if err != nil {
+				log.Println("get tag error:", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if tag == nil {
+				http.NotFound(w, r)
+				return
+			}
+			data.Tag = tag
+			// The search box searches everything, not just the tag.
+			data.Query = ""
+		}
+
+		if data.Query != "" {
+			results, err := repo.Search(ctx, data.Query, models.StatusApproved)
+			if err != nil {
+				log.Println("search projects error:", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			data.Projects, data.Snippets = splitSearchResults(results)
+		} else {
+			req := pageRequest(r, pageSize, repository.SortNewest)
+			var page repository.ProjectPage
+			var err error
+			if data.Tag != nil {
+				page, err = repo.ListApprovedByTag(ctx, data.Tag.Slug, req)
+			} else {
+				page, err = repo.ListApproved(ctx, req)
+			}
+			if err != nil {
+				writeListError(w, "list projects error:", err)
+				return
+			}
+			data.Projects = page.Projects
+			data.Pagination = newPagination(r, req, page)
+		}
+
 		if err := t.ExecuteTemplate(w, "projects", data); err != nil {
 			log.Println("template execute error:", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -282,7 +317,55 @@ func ProjectDetail(t *template.Template, repo *repository.ProjectRepository, ses
 	}
 }
 
-func generateImageFilename(original string) string {
-	ext := filepath.Ext(original)
-	return fmt.Sprintf("%s%s", uuid.New().String(), ext)
+// descriptionProblem checks a Markdown description against both length
+// limits and returns a message for the author, or "" if it is fine.
+func descriptionProblem(r *http.Request, description string) string {
+	var problem string
+	source := utf8.RuneCountInString(description)
+	text := utf8.RuneCountInString(markdown.PlainText(description))
+
+	switch {
+	case text > models.MaxDescriptionText:
+		problem = fmt.Sprintf("Description must be at most %d characters of text", models.MaxDescriptionText)
+	case source > models.MaxDescriptionSource:
+		problem = fmt.Sprintf("Description must be at most %d characters including formatting", models.MaxDescriptionSource)
+	default:
+		return ""
+	}
+
+	slog.WarnContext(
+		r.Context(),
+		"project description too long",
+		"event.category", "validation",
+		"field", "description",
+		"length", source,
+		"text_length", text,
+	)
+	return problem
+}
+
+// PreviewDescription renders the posted Markdown description the way the
+// project page will show it, as an HTML fragment for the form preview.
+func PreviewDescription() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		description := strings.TrimSpace(r.FormValue("description"))
+		if problem := descriptionProblem(r, description); problem != "" {
+			http.Error(w, problem, http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		fmt.Fprint(w, markdown.Render(description))
+	}
+}
+
+// requestedStatus maps the submit button an author pressed to the status
+// the project should get: "draft" keeps it private, anything else submits
+// it for review.
+func requestedStatus(r *http.Request) models.ProjectStatus {
+	if r.FormValue("action") == "draft" {
+		return models.StatusDraft
+	}
+	return models.StatusPending
 }
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/url"
+	"strings"
+)
+
+// defaultAfterLogin is where users land when no (valid) next path was given.
+const defaultAfterLogin = "/projects/new"
+
+// safeNext returns next if it is a local absolute path such as
+// "/admin/projects?tab=pending", and "" otherwise. Scheme-relative
+// ("//evil.example"), backslash and absolute URLs are rejected so the
+// value can never be used as an open redirect.
+func safeNext(next string) string {
+	if next == "" || !strings.HasPrefix(next, "/") {
+		return ""
+	}
+
+	if strings.HasPrefix(next, "//") || strings.ContainsAny(next, "\\\r\n\t") {
+		return ""
+	}
+
+	u, err := url.Parse(next)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.User != nil {
+		return ""
+	}
+
+	return next
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import "testing"
+
+func TestSafeNext(t *testing.T) {
+	tests := []struct {
+		next string
+		want string
+	}{
+		{"", ""},
+		{"/projects/new", "/projects/new"},
+		{"/admin/projects?tab=pending", "/admin/projects?tab=pending"},
+		{"/", "/"},
+		{"//evil.example", ""},
+		{"//evil.example/path", ""},
+		{"/\\evil.example", ""},
+		{"\\\\evil.example", ""},
+		{"http://evil.example", ""},
+		{"https://evil.example/projects", ""},
+		{"javascript:alert(1)", ""},
+		{"%2F%2Fevil.example", ""},
+		{"evil.example", ""},
+		{"projects/new", ""},
+		{"/\r\nLocation: http://evil.example", ""},
+		{"/\tevil", ""},
+	}
+
+	for _, tt := range tests {
+		if got := safeNext(tt.next); got != tt.want {
+			t.Errorf("safeNext(%q) = %q, want %q", tt.next, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"log/slog"
+	"net/http"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/janphilippgutt/casproject/internal/auth"
+	"github.com/janphilippgutt/casproject/internal/db"
+	"github.com/janphilippgutt/casproject/internal/models"
+)
+
+// verifyLinkTTL is how long an email verification link stays valid.
+const verifyLinkTTL = 24 * time.Hour
+
+type RegisterData struct {
+	BasePageData
+	Email string
+	Error string
+	Sent  bool
+}
+
+// Register implements open self-service sign-up. The account is only
+// created once the user follows the emailed verification link. Known
+// addresses get a normal login link instead, and both cases render the
+// same confirmation page.
+func Register(t *template.Template, sess *scs.SessionManager, pool *pgxpool.Pool, links *auth.MagicLinks) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		render := func(data RegisterData) {
+			if data.Sent {
+				data.BasePageData = NewBaseData(ctx, sess)
+			} else {
+				data.BasePageData = NewFormBaseData(ctx, sess)
+			}
+			if err := t.ExecuteTemplate(w, "register", data); err != nil {
+				log.Println("template execute error:", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			}
+		}
+
+		switch r.Method {
+
+		case http.MethodGet:
+			render(RegisterData{})
+
+		case http.MethodPost:
+			input := strings.TrimSpace(r.FormValue("email"))
+			addr, err := netmail.ParseAddress(input)
+			if err != nil {
+				render(RegisterData{Email: input, Error: "Please enter a valid email address"})
+				return
+			}
+			email := addr.Address
+
+			lt := auth.LoginToken{Email: email, Purpose: auth.PurposeVerify, Role: models.RoleUser}
+			ttl, tpl, subject := verifyLinkTTL, "verify", "Confirm your ProjectHub account"
+
+			user, err := db.GetUserByEmail(ctx, pool, email)
+			switch {
+			case err == nil && !user.Active():
+				// Deactivated accounts can't log in; answer as usual without sending.
+				render(RegisterData{Email: email, Sent: true})
+				return
+			case err == nil:
+				lt = auth.LoginToken{Email: email, Purpose: auth.PurposeLogin}
+				ttl, tpl, subject = loginLinkTTL, "login", "Your ProjectHub login link"
+			case !errors.Is(err, pgx.ErrNoRows):
+				log.Println("register user lookup error:", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			// Sent in the background so new and existing addresses get the
+			// same answer in the same time.
+			links.SendAsync(ctx, lt, ttl, tpl, subject)
+
+			slog.LogAttrs(
+				ctx,
+				slog.LevelInfo,
+				"registration link issued",
+				slog.String("event.category", "auth"),
+				slog.String("event.type", "register"),
+				slog.String("user.id", email),
+				slog.String("token.purpose", string(lt.Purpose)),
+			)
+
+			render(RegisterData{Email: email, Sent: true})
+
+		default:
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// RegisterLinkSent renders the confirmation page without sending anything;
+// used when the per-email rate limit trips.
+func RegisterLinkSent(t *template.Template, sess *scs.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := RegisterData{
+			BasePageData: NewBaseData(r.Context(), sess),
+			Email:        strings.TrimSpace(r.PostFormValue("email")),
+			Sent:         true,
+		}
+		if err := t.ExecuteTemplate(w, "register", data); err != nil {
+			log.Println("template execute error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"html/template"
+	"strings"
+
+	"github.com/janphilippgutt/casproject/internal/models"
+	"github.com/janphilippgutt/casproject/internal/repository"
+)
+
+// maxSearchQuery bounds the length of a search query.
+const maxSearchQuery = 200
+
+// searchQuery returns the trimmed ?q= parameter, cut to maxSearchQuery bytes.
+func searchQuery(q string) string {
+	q = strings.TrimSpace(q)
+	if len(q) > maxSearchQuery {
+		q = strings.ToValidUTF8(q[:maxSearchQuery], "")
+	}
+	return q
+}
+
+// splitSearchResults separates search results into the plain projects
+// templates already know how to render and their highlighted snippets,
+// keyed by project ID.
+func splitSearchResults(results []repository.ProjectSearchResult) ([]models.Project, map[int]template.HTML) {
+	projects := make([]models.Project, 0, len(results))
+	snippets := make(map[int]template.HTML, len(results))
+	for _, res := range results {
+		projects = append(projects, res.Project)
+		snippets[res.ID] = highlightSnippet(res.Snippet)
+	}
+	return projects, snippets
+}
+
+// highlightSnippet HTML-escapes a snippet and wraps the marked matches in
+// <mark>. Markers that made it into the stored text itself can at most
+// produce extra <mark> elements; tags are always balanced.
+func highlightSnippet(s string) template.HTML {
+	var b strings.Builder
+	open := false
+	for {
+		i := strings.IndexAny(s, repository.HighlightStart+repository.HighlightStop)
+		if i < 0 {
+			break
+		}
+		b.WriteString(template.HTMLEscapeString(s[:i]))
+		switch {
+		case s[i:i+1] == repository.HighlightStart && !open:
+			b.WriteString("<mark>")
+			open = true
+		case s[i:i+1] == repository.HighlightStop && open:
+			b.WriteString("</mark>")
+			open = false
+		}
+		s = s[i+1:]
+	}
+	b.WriteString(template.HTMLEscapeString(s))
+	if open {
+		b.WriteString("</mark>")
+	}
+	return template.HTML(b.String())
+}
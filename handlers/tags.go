@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/janphilippgutt/casproject/internal/models"
+	"github.com/janphilippgutt/casproject/internal/repository"
+)
+
+// formTags collects the tags of a submission form: the checked curated
+// tags ("tags") plus the comma-separated free tags ("free_tags"). A
+// non-empty problem is a message for the author.
+func formTags(r *http.Request) (names []string, problem string) {
+	names = append([]string{}, r.Form["tags"]...)
+	names = append(names, strings.Split(r.FormValue("free_tags"), ",")...)
+	names = models.CleanTagNames(names)
+
+	if len(names) > models.MaxTagsPerProject {
+		return nil, fmt.Sprintf("A project can have at most %d tags", models.MaxTagsPerProject)
+	}
+	for _, name := range names {
+		if len(name) > models.MaxTagLength {
+			return nil, fmt.Sprintf("Tags must be at most %d characters", models.MaxTagLength)
+		}
+	}
+	return names, ""
+}
+
+// freeTagNames joins the tags of p that are not in the curated vocabulary,
+// for the free tags input of the edit form.
+func freeTagNames(p *models.Project) string {
+	var names []string
+	for _, t := range p.Tags {
+		if !t.Curated {
+			names = append(names, t.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// sameTags reports whether names resolve to exactly the tags in current.
+func sameTags(current []models.Tag, names []string) bool {
+	if len(current) != len(names) {
+		return false
+	}
+	slugs := make([]string, len(names))
+	for i, name := range names {
+		slugs[i] = models.TagSlug(name)
+	}
+	for _, t := range current {
+		if !slices.Contains(slugs, t.Slug) {
+			return false
+		}
+	}
+	return true
+}
+
+type TagsPageData struct {
+	BasePageData
+	Tags []models.Tag
+}
+
+// Tags lists the categories and the free tags in use.
+func Tags(t *template.Template, repo *repository.TagRepository, sess *scs.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tags, err := repo.ListPublic(r.Context())
+		if err != nil {
+			log.Println("list tags error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		data := TagsPageData{
+			BasePageData: NewBaseData(r.Context(), sess),
+			Tags:         tags,
+		}
+		if err := t.ExecuteTemplate(w, "tags", data); err != nil {
+			log.Println("template execute error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+	}
+}
+
+type AdminTagsData struct {
+	BasePageData
+	Tags  []models.Tag
+	Flash string
+	Error string
+}
+
+// AdminTags shows every tag with its usage and the forms to curate them.
+func AdminTags(t *template.Template, repo *repository.TagRepository, sess *scs.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		tags, err := repo.ListAll(ctx)
+		if err != nil {
+			log.Println("list tags error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		data := AdminTagsData{
+			BasePageData: NewBaseData(ctx, sess),
+			Tags:         tags,
+			Flash:        sess.PopString(ctx, "flash"),
+			Error:        sess.PopString(ctx, "flash_error"),
+		}
+		if err := t.ExecuteTemplate(w, "admin_tags", data); err != nil {
+			log.Println("template execute error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// CreateCuratedTag adds a tag to the curated vocabulary, promoting a free
+// tag of the same name if there is one.
+func CreateCuratedTag(repo *repository.TagRepository, sess *scs.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		name := strings.Join(strings.Fields(r.FormValue("name")), " ")
+		description := strings.TrimSpace(r.FormValue("description"))
+
+		switch {
+		case models.TagSlug(name) == "":
+			sess.Put(ctx, "flash_error", "Tag names need at least one letter or digit")
+		case len(name) > models.MaxTagLength:
+			sess.Put(ctx, "flash_error", fmt.Sprintf("Tag names must be at most %d characters", models.MaxTagLength))
+		case len(description) > 500:
+			sess.Put(ctx, "flash_error", "Descriptions must be at most 500 characters")
+		default:
+			if err := repo.SaveCurated(ctx, name, description); err != nil {
+				log.Println("save curated tag error:", err)
+				http.Error(w, "Could not save tag", http.StatusInternalServerError)
+				return
+			}
+			logTagAction(r, sess, "tag_create", name)
+			sess.Put(ctx, "flash", "Category “"+name+"” saved")
+		}
+
+		http.Redirect(w, r, "/admin/tags", http.StatusSeeOther)
+	}
+}
+
+// SetTagCurated promotes a free tag to a category (curated=true) or
+// demotes a category to a free tag.
+func SetTagCurated(repo *repository.TagRepository, sess *scs.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid tag ID", http.StatusBadRequest)
+			return
+		}
+
+		curated := r.FormValue("curated") == "true"
+		if err := repo.SetCurated(ctx, id, curated); err != nil {
+			log.Println("set tag curated error:", err)
+			http.Error(w, "Could not update tag", http.StatusInternalServerError)
+			return
+		}
+
+		action := "tag_demote"
+		if curated {
+			action = "tag_promote"
+		}
+		logTagAction(r, sess, action, strconv.Itoa(id))
+
+		http.Redirect(w, r, "/admin/tags", http.StatusSeeOther)
+	}
+}
+
+// DeleteTag removes a tag from every project.
+func DeleteTag(repo *repository.TagRepository, sess *scs.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Invalid tag ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := repo.Delete(ctx, id); err != nil {
+			log.Println("delete tag error:", err)
+			http.Error(w, "Could not delete tag", http.StatusInternalServerError)
+			return
+		}
+
+		logTagAction(r, sess, "tag_delete", strconv.Itoa(id))
+		sess.Put(ctx, "flash", "Tag deleted")
+		http.Redirect(w, r, "/admin/tags", http.StatusSeeOther)
+	}
+}
+
+func logTagAction(r *http.Request, sess *scs.SessionManager, action, tag string) {
+	slog.Info(
+		"tag updated",
+		"event.category", "admin",
+		"event.type", action,
+		"user.id", sess.GetString(r.Context(), "user_email"),
+		"tag", tag,
+	)
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/janphilippgutt/casproject/internal/auth"
+	"github.com/janphilippgutt/casproject/internal/imaging"
+	"github.com/janphilippgutt/casproject/internal/repository"
+	"github.com/janphilippgutt/casproject/internal/storage"
+)
+
+// uploadError is an upload problem with the message and status to show the user.
+type uploadError struct {
+	msg    string
+	status int
+}
+
+func (e *uploadError) Error() string { return e.msg }
+
+// uploadCount returns how many files were chosen in the given multipart
+// field. Browsers send an empty part for a file input left empty.
+func uploadCount(r *http.Request, field string) int {
+	if r.MultipartForm == nil {
+		return 0
+	}
+	n := 0
+	for _, header := range r.MultipartForm.File[field] {
+		if header.Filename != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// saveImageUploads stores every JPEG or PNG sent in the given multipart
+// field under projects/ in store and returns their keys in the order they
+// were sent. If one file is rejected, the ones already stored are removed
+// again.
+func saveImageUploads(r *http.Request, store storage.Store, field string) ([]string, error) {
+	if r.MultipartForm == nil {
+		return nil, nil
+	}
+
+	var keys []string
+	for _, header := range r.MultipartForm.File[field] {
+		if header.Filename == "" {
+			continue // optional file not provided
+		}
+		key, err := saveImageFile(r.Context(), store, header)
+		if err != nil {
+			removeUploads(r.Context(), store, keys)
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// saveImageFile decodes an uploaded image, stores it re-encoded without
+// metadata together with its resized variants and returns the key of the
+// original.
+func saveImageFile(ctx context.Context, store storage.Store, header *multipart.FileHeader) (string, error) {
+	file, err := header.Open()
+	if err != nil {
+		log.Println("upload error:", err)
+		return "", &uploadError{"Invalid file upload", http.StatusBadRequest}
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", &uploadError{"Invalid file stream", http.StatusBadRequest}
+	}
+
+	// Only what decodes as a JPEG or PNG is kept, and only its pixels.
+	img, err := imaging.Process(data)
+	switch {
+	case errors.Is(err, imaging.ErrTooLarge):
+		return "", &uploadError{"Image dimensions are too large", http.StatusBadRequest}
+	case err != nil:
+		slog.Warn(
+			"image upload rejected",
+			"event.category", "validation",
+			"field", "images",
+			"error", err,
+		)
+		return "", &uploadError{"Only JPEG and PNG allowed", http.StatusBadRequest}
+	}
+
+	key := "projects/" + uuid.New().String() + img.Ext
+	err = store.Put(ctx, key, bytes.NewReader(img.Original), int64(len(img.Original)), img.ContentType)
+	for _, v := range img.Variants {
+		if err != nil {
+			break
+		}
+		err = store.Put(ctx, imaging.VariantKey(key, v.Name), bytes.NewReader(v.Data), int64(len(v.Data)), img.ContentType)
+	}
+	if err != nil {
+		slog.Error(
+			"store upload failed",
+			"event.category", "file",
+			"event.type", "creation",
+			"key", key,
+			"error", err,
+		)
+		removeUploads(ctx, store, []string{key})
+		return "", &uploadError{"Could not save image", http.StatusInternalServerError}
+	}
+
+	return key, nil
+}
+
+// removeUploads deletes stored uploads together with their variants.
+// Failures are only logged; a leftover file does no harm.
+func removeUploads(ctx context.Context, store storage.Store, keys []string) {
+	for _, key := range keys {
+		victims := []string{key}
+		for _, v := range imaging.Variants {
+			victims = append(victims, imaging.VariantKey(key, v.Name))
+		}
+		for _, k := range victims {
+			if err := store.Delete(ctx, k); err != nil {
+				slog.Warn(
+					"remove upload failed",
+					"event.category", "file",
+					"event.type", "deletion",
+					"key", k,
+					"error", err,
+				)
+			}
+		}
+	}
+}
+
+// UploadPath is the app URL of the upload stored under key. Pages that
+// may show images of unpublished projects sign it.
+func UploadPath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return "/uploads/" + strings.Join(segments, "/")
+}
+
+// ServeUpload serves uploaded images. Images of public projects are
+// served to everyone; all others only through a valid signed URL.
+func ServeUpload(repo *repository.ProjectRepository, store storage.Store, signer *auth.URLSigner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		key := chi.URLParam(r, "*")
+
+		// Public images are cached briefly, so they stop being served soon
+		// after their project is unpublished.
+		cacheControl := "public, max-age=600"
+		if expires, ok := signer.Verify(r.URL.EscapedPath(), r.URL.Query()); ok {
+			cacheControl = "private, max-age=" + strconv.Itoa(int(time.Until(expires).Seconds()))
+		} else {
+			public, err := repo.ImageIsPublic(ctx, imaging.OriginalKey(key))
+			if err != nil {
+				log.Println("image access check error:", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if !public {
+				http.NotFound(w, r)
+				return
+			}
+		}
+
+		// Stores with their own URLs (S3) serve the bytes themselves.
+		direct, err := store.URL(ctx, key, 15*time.Minute)
+		if err != nil {
+			log.Println("upload url error:", err)
+			http.NotFound(w, r)
+			return
+		}
+		if direct != "" {
+			w.Header().Set("Cache-Control", "private, max-age=600")
+			http.Redirect(w, r, direct, http.StatusFound)
+			return
+		}
+
+		file, err := store.Get(ctx, key)
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			log.Println("read upload error:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+
+		contentType := mime.TypeByExtension(path.Ext(key))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", cacheControl)
+
+		if rs, ok := file.(io.ReadSeeker); ok {
+			http.ServeContent(w, r, "", time.Time{}, rs)
+			return
+		}
+		io.Copy(w, file)
+	}
+}
+
+func writeUploadError(w http.ResponseWriter, err error) {
+	var ue *uploadError
+	if errors.As(err, &ue) {
+		http.Error(w, ue.msg, ue.status)
+		return
+	}
+	http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+}
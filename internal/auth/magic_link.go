@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/janphilippgutt/casproject/internal/mail"
+)
+
+// MagicLinks issues single-use tokens and emails the resulting
+// /magic-login links. It is shared by login, invites and registration.
+type MagicLinks struct {
+	Tokens  TokenStore
+	Mailer  mail.Mailer
+	Emails  *mail.Templates
+	BaseURL string
+}
+
+// sendTimeout bounds a background delivery started by SendAsync.
+const sendTimeout = 2 * time.Minute
+
+// LinkEmailData feeds the templates in templates/email.
+type LinkEmailData struct {
+	Link      string
+	ExpiresIn string
+	Role      string
+}
+
+// Send stores lt under a fresh token and mails the link to lt.Email using
+// the named email template pair.
+func (m *MagicLinks) Send(ctx context.Context, lt LoginToken, ttl time.Duration, template, subject string) error {
+	token, err := GenerateToken(32)
+	if err != nil {
+		return fmt.Errorf("generate token: %w", err)
+	}
+
+	if err := m.Tokens.Add(ctx, token, lt, ttl); err != nil {
+		return fmt.Errorf("store token: %w", err)
+	}
+
+	msg, err := m.Emails.Render(template, lt.Email, subject, LinkEmailData{
+		Link:      m.BaseURL + "/magic-login?token=" + url.QueryEscape(token),
+		ExpiresIn: humanDuration(ttl),
+		Role:      lt.Role,
+	})
+	if err != nil {
+		return fmt.Errorf("render email: %w", err)
+	}
+
+	if err := m.Mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("send email: %w", err)
+	}
+
+	return nil
+}
+
+// SendAsync sends the link like Send, but in the background, so answering
+// the request takes the same time whether or not a link goes out. Failures
+// are logged. The delivery keeps ctx's values but not its cancellation.
+func (m *MagicLinks) SendAsync(ctx context.Context, lt LoginToken, ttl time.Duration, template, subject string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+	go func() {
+		defer cancel()
+		if err := m.Send(ctx, lt, ttl, template, subject); err != nil {
+			slog.LogAttrs(
+				ctx,
+				slog.LevelError,
+				"magic link delivery failed",
+				slog.String("event.category", "auth"),
+				slog.String("event.type", "error"),
+				slog.String("user.id", lt.Email),
+				slog.String("token.purpose", string(lt.Purpose)),
+				slog.Any("error", err),
+			)
+		}
+	}()
+}
+
+func humanDuration(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%d days", int(d.Hours()/24))
+	case d >= 2*time.Hour:
+		return fmt.Sprintf("%d hours", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%d minutes", int(d.Minutes()))
+	}
+}
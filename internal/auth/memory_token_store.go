@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryTokenStore keeps tokens in a process-local map. Tokens are lost on
+// restart and are not shared between instances; use it for local dev and tests.
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]tokenEntry
+}
+
+type tokenEntry struct {
+	LoginToken
+	expiresAt time.Time
+}
+
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		tokens: make(map[string]tokenEntry),
+	}
+}
+
+func (s *MemoryTokenStore) Add(ctx context.Context, token string, lt LoginToken, ttl time.Duration) error { // ttl = "time to live"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token] = tokenEntry{
+		LoginToken: lt,
+		expiresAt:  time.Now().Add(ttl),
+	}
+	return nil
+}
+
+func (s *MemoryTokenStore) Use(ctx context.Context, token string) (LoginToken, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.tokens[token]
+	if !ok {
+		return LoginToken{}, false, nil
+	}
+
+	if time.Now().After(entry.expiresAt) {
+		delete(s.tokens, token)
+		return LoginToken{}, false, nil
+	}
+
+	delete(s.tokens, token)
+	return entry.LoginToken, true, nil
+}
+
+func (s *MemoryTokenStore) CleanupExpired(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for token, entry := range s.tokens {
+		if now.After(entry.expiresAt) {
+			delete(s.tokens, token)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryTokenStore) StartCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.CleanupExpired(context.Background())
+		}
+	}()
+}
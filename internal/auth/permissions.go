@@ -0,0 +1,48 @@
+package auth
+
+import "github.com/janphilippgutt/casproject/internal/models"
+
+// Permission names a single capability. Routes and templates check
+// permissions, never role names, so roles can change without touching them.
+type Permission string
+
+const (
+	// PermProjectsApprove covers the moderation queue: approve and unapprove.
+	PermProjectsApprove Permission = "projects.approve"
+	// PermProjectsArchive covers archiving, restoring and viewing archived projects.
+	PermProjectsArchive       Permission = "projects.archive"
+	PermProjectsDeleteForever Permission = "projects.delete_forever"
+	// PermUsersManage covers the admin dashboard, invitations and /admin/users.
+	PermUsersManage Permission = "users.manage"
+	// PermTagsManage covers the curated tag vocabulary at /admin/tags.
+	PermTagsManage Permission = "tags.manage"
+)
+
+var rolePermissions = map[string][]Permission{
+	models.RoleUser: {},
+	models.RoleModerator: {
+		PermProjectsApprove,
+	},
+	models.RoleAdmin: {
+		PermProjectsApprove,
+		PermProjectsArchive,
+		PermProjectsDeleteForever,
+		PermUsersManage,
+		PermTagsManage,
+	},
+}
+
+// HasPermission reports whether role grants p. Unknown roles grant nothing.
+func HasPermission(role string, p Permission) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAnyPermission reports whether role grants any permission at all.
+func HasAnyPermission(role string) bool {
+	return len(rolePermissions[role]) > 0
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresTokenStore persists tokens in the login_tokens table so login
+// links survive restarts and work across instances. Only SHA-256 hashes
+// of the tokens are stored.
+type PostgresTokenStore struct {
+	DB *pgxpool.Pool
+}
+
+func NewPostgresTokenStore(pool *pgxpool.Pool) *PostgresTokenStore {
+	return &PostgresTokenStore{DB: pool}
+}
+
+func (s *PostgresTokenStore) Add(ctx context.Context, token string, lt LoginToken, ttl time.Duration) error {
+	_, err := s.DB.Exec(ctx, `
+		INSERT INTO login_tokens (token_hash, email, purpose, role, next_path, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`,
+		hashToken(token),
+		lt.Email,
+		lt.Purpose,
+		lt.Role,
+		lt.Next,
+		time.Now().Add(ttl),
+	)
+
+	return err
+}
+
+func (s *PostgresTokenStore) Use(ctx context.Context, token string) (LoginToken, bool, error) {
+	var (
+		lt        LoginToken
+		expiresAt time.Time
+	)
+
+	// DELETE ... RETURNING makes consumption atomic: of two concurrent
+	// requests with the same token only one gets a row back.
+	err := s.DB.QueryRow(ctx, `
+		DELETE FROM login_tokens
+		WHERE token_hash = $1
+		RETURNING email, purpose, role, next_path, expires_at
+	`, hashToken(token)).Scan(&lt.Email, &lt.Purpose, &lt.Role, &lt.Next, &expiresAt)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return LoginToken{}, false, nil
+		}
+		return LoginToken{}, false, err
+	}
+
+	if time.Now().After(expiresAt) {
+		return LoginToken{}, false, nil
+	}
+
+	return lt, true, nil
+}
+
+func (s *PostgresTokenStore) CleanupExpired(ctx context.Context) error {
+	_, err := s.DB.Exec(ctx, `
+		DELETE FROM login_tokens
+		WHERE expires_at < NOW()
+	`)
+	return err
+}
+
+// StartCleanup periodically purges expired tokens from the database.
+func (s *PostgresTokenStore) StartCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := s.CleanupExpired(ctx); err != nil {
+				slog.Error(
+					"login token cleanup failed",
+					"event.category", "auth",
+					"event.type", "cleanup",
+					"error", err,
+				)
+			}
+			cancel()
+		}
+	}()
+}
@@ -1,26 +1,42 @@
 package auth
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
-	"sync"
+	"encoding/hex"
 	"time"
 )
 
-type TokenStore struct {
-	mu     sync.Mutex
-	tokens map[string]tokenEntry // token -> email
-}
+// Purpose says what using a token does. Invite and verify tokens create
+// the account on first use; login tokens require an existing one.
+type Purpose string
+
+const (
+	PurposeLogin  Purpose = "login"
+	PurposeInvite Purpose = "invite"
+	PurposeVerify Purpose = "verify"
+)
 
-type tokenEntry struct {
-	email     string
-	expiresAt time.Time
+// LoginToken is what a magic link resolves to once it is used.
+type LoginToken struct {
+	Email   string
+	Purpose Purpose
+	// Role is assigned when an invite or verify token creates the account.
+	Role string
+	// Next is the internal path the user wanted before being sent to /login.
+	// It is bound to the token so it never has to travel in the emailed URL.
+	Next string
 }
 
-func NewTokenStore() *TokenStore {
-	return &TokenStore{
-		tokens: make(map[string]tokenEntry),
-	}
+// TokenStore keeps magic login tokens until they are used once or expire.
+type TokenStore interface {
+	Add(ctx context.Context, token string, lt LoginToken, ttl time.Duration) error
+	// Use consumes the token. ok is false if the token is unknown or expired.
+	Use(ctx context.Context, token string) (lt LoginToken, ok bool, err error)
+	CleanupExpired(ctx context.Context) error
+	StartCleanup(interval time.Duration)
 }
 
 func GenerateToken(n int) (string, error) {
@@ -32,52 +48,9 @@ func GenerateToken(n int) (string, error) {
 	return base64.URLEncoding.EncodeToString(b), nil
 }
 
-func (s *TokenStore) Add(token, email string, ttl time.Duration) { // ttl = "time to live"
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.tokens[token] = tokenEntry{
-		email:     email,
-		expiresAt: time.Now().Add(ttl),
-	}
-}
-
-func (s *TokenStore) Use(token string) (string, bool) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	entry, ok := s.tokens[token]
-	if !ok {
-		return "", false
-	}
-
-	if time.Now().After(entry.expiresAt) {
-		delete(s.tokens, token)
-		return "", false
-	}
-
-	delete(s.tokens, token)
-	return entry.email, true
-}
-
-func (s *TokenStore) CleanupExpired() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	now := time.Now()
-	for token, entry := range s.tokens {
-		if now.After(entry.expiresAt) {
-			delete(s.tokens, token)
-		}
-	}
-}
-
-func (s *TokenStore) StartCleanup(interval time.Duration) {
-	go func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			s.CleanupExpired()
-		}
-	}()
+// hashToken is what persistent stores keep instead of the raw token,
+// so a leaked table does not contain usable login links.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
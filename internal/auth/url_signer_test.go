@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestURLSigner(t *testing.T) {
+	s := &URLSigner{Secret: []byte("test secret"), TTL: time.Hour}
+
+	const path = "/uploads/projects/abc_card.webp"
+
+	validPath, valid := signedURL(t, s, path)
+	if validPath != path {
+		t.Fatalf("Sign(%q) changed the path to %q", path, validPath)
+	}
+
+	expired := url.Values{}
+	past := time.Now().Add(-time.Minute).Unix()
+	expired.Set("exp", strconv.FormatInt(past, 10))
+	expired.Set("sig", s.signature(path, past))
+
+	laterExpiry := url.Values{}
+	laterExpiry.Set("exp", strconv.FormatInt(time.Now().Add(24*time.Hour).Unix(), 10))
+	laterExpiry.Set("sig", valid.Get("sig"))
+
+	tamperedSig := url.Values{}
+	tamperedSig.Set("exp", valid.Get("exp"))
+	tamperedSig.Set("sig", strings.Repeat("A", len(valid.Get("sig"))))
+
+	_, other := signedURL(t, s, "/uploads/projects/abc_full.webp")
+	_, otherSecret := signedURL(t, &URLSigner{Secret: []byte("other secret"), TTL: time.Hour}, path)
+
+	tests := []struct {
+		name  string
+		path  string
+		query url.Values
+		ok    bool
+	}{
+		{"valid", path, valid, true},
+		{"expired", path, expired, false},
+		{"tampered key", "/uploads/projects/xyz_card.webp", valid, false},
+		{"tampered expiry", path, laterExpiry, false},
+		{"tampered signature", path, tamperedSig, false},
+		{"other variant's signature", path, other, false},
+		{"original with a variant's signature", "/uploads/projects/abc.png", valid, false},
+		{"other secret", path, otherSecret, false},
+		{"unsigned", path, url.Values{}, false},
+		{"malformed expiry", path, url.Values{"exp": {"soon"}, "sig": {valid.Get("sig")}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expires, ok := s.Verify(tt.path, tt.query)
+			if ok != tt.ok {
+				t.Fatalf("Verify(%q, %v) ok = %v, want %v", tt.path, tt.query, ok, tt.ok)
+			}
+			if ok && (expires.Before(time.Now().Add(s.TTL)) || expires.After(time.Now().Add(s.TTL*3/2))) {
+				t.Errorf("expires = %v, want between TTL and 1.5 TTL from now", expires)
+			}
+		})
+	}
+}
+
+// signedURL splits the URL s.Sign returns for p into its path and query.
+func signedURL(t *testing.T, s *URLSigner, p string) (string, url.Values) {
+	t.Helper()
+	u, err := url.Parse(s.Sign(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Path, u.Query()
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/janphilippgutt/casproject/internal/models"
+)
+
+// UserSource loads a user by email, returning nil (and no error) if there is none.
+type UserSource interface {
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+}
+
+// UserCache keeps recently loaded users for a short TTL so sessions can be
+// revalidated on every request without a database round trip each time.
+// Invalidate after changing a user to make the change visible immediately
+// on this instance; other instances pick it up once their entry expires.
+type UserCache struct {
+	source  UserSource
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]cachedUser
+}
+
+type cachedUser struct {
+	user      *models.User // nil if the user does not exist
+	expiresAt time.Time
+}
+
+func NewUserCache(source UserSource, ttl time.Duration) *UserCache {
+	return &UserCache{
+		source:  source,
+		ttl:     ttl,
+		entries: make(map[string]cachedUser),
+	}
+}
+
+func (c *UserCache) Get(ctx context.Context, email string) (*models.User, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[email]
+	c.mu.Unlock()
+
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.user, nil
+	}
+
+	user, err := c.source.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[email] = cachedUser{user: user, expiresAt: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+
+	return user, nil
+}
+
+func (c *UserCache) Invalidate(email string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, email)
+}
+
+func (c *UserCache) CleanupExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for email, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, email)
+		}
+	}
+}
+
+func (c *UserCache) StartCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			c.CleanupExpired()
+		}
+	}()
+}
@@ -0,0 +1,228 @@
+// Package config reads application settings from the environment.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	// Embedded zone data, so APP_TIMEZONE works in images without tzdata.
+	_ "time/tzdata"
+)
+
+type Config struct {
+	Port string
+	// BaseURL is the externally reachable origin used to build links in
+	// emails, e.g. https://projects.example.com (no trailing slash).
+	BaseURL string
+	// Timezone is the zone moderators enter publication times in and the
+	// pages show times in (APP_TIMEZONE, default UTC).
+	Timezone   *time.Location
+	TokenStore string
+	// SessionStore is "postgres" (default) or "memory". The in-memory store
+	// loses all sessions on restart and is meant for tests and local dev.
+	SessionStore string
+	// TrustProxyHeaders makes the app take the client IP from
+	// X-Forwarded-For / X-Real-IP. Only enable behind a trusted proxy.
+	TrustProxyHeaders bool
+	// RegistrationOpen enables self-service sign-up with email verification.
+	// Otherwise accounts are only created through admin invitations.
+	RegistrationOpen bool
+	LoginRateLimit   RateLimitConfig
+	// UserCacheTTL bounds how long a role change or deactivation can take
+	// to reach sessions served by other instances.
+	UserCacheTTL time.Duration
+	// PageSize is the number of projects per page in paginated lists.
+	PageSize int
+	Mail     MailConfig
+	Storage  StorageConfig
+}
+
+// RateLimitConfig bounds magic link requests per client IP and per email
+// address within Window.
+type RateLimitConfig struct {
+	Window   time.Duration
+	PerIP    int
+	PerEmail int
+}
+
+type MailConfig struct {
+	Driver       string // "smtp" or "outbox"
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	// SMTPTimeout bounds one delivery, so a hanging relay can't block.
+	SMTPTimeout time.Duration
+	OutboxDir   string
+}
+
+// StorageConfig selects where uploaded files are kept: "local" (a
+// directory served by the app) or "s3" (an S3-compatible bucket).
+type StorageConfig struct {
+	Backend           string
+	LocalDir          string
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3UseSSL          bool
+	// URLSecret signs the expiring URLs of images that are not public.
+	// Empty means a random secret per process: URLs then stop working on
+	// restart and are not shared between instances.
+	URLSecret string
+	// URLTTL is how long signed image URLs stay valid.
+	URLTTL time.Duration
+	// GCInterval is how often files no project refers to are deleted.
+	GCInterval time.Duration
+	// GCGrace is how old such a file must be before it is deleted.
+	GCGrace time.Duration
+}
+
+func Load() (Config, error) {
+	var err error
+
+	port := getenv("PORT", "8080")
+
+	cfg := Config{
+		Port:         port,
+		BaseURL:      strings.TrimRight(getenv("APP_BASE_URL", "http://localhost:"+port), "/"),
+		TokenStore:   getenv("TOKEN_STORE", "postgres"),
+		SessionStore: getenv("SESSION_STORE", "postgres"),
+		Mail: MailConfig{
+			Driver:       getenv("MAIL_DRIVER", "outbox"),
+			From:         getenv("MAIL_FROM", "ProjectHub <no-reply@localhost>"),
+			SMTPHost:     os.Getenv("SMTP_HOST"),
+			SMTPPort:     getenv("SMTP_PORT", "587"),
+			SMTPUsername: os.Getenv("SMTP_USERNAME"),
+			SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+			OutboxDir:    getenv("MAIL_OUTBOX_DIR", "./outbox"),
+		},
+		Storage: StorageConfig{
+			Backend:           getenv("STORAGE_BACKEND", "local"),
+			LocalDir:          getenv("UPLOAD_DIR", "./uploads"),
+			S3Endpoint:        os.Getenv("S3_ENDPOINT"),
+			S3Region:          getenv("S3_REGION", "us-east-1"),
+			S3Bucket:          os.Getenv("S3_BUCKET"),
+			S3AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			S3SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			S3UseSSL:          os.Getenv("S3_USE_SSL") != "false",
+			URLSecret:         os.Getenv("UPLOAD_URL_SECRET"),
+		},
+	}
+
+	if cfg.Timezone, err = time.LoadLocation(getenv("APP_TIMEZONE", "UTC")); err != nil {
+		return Config{}, fmt.Errorf("APP_TIMEZONE must be a time zone like Europe/Berlin: %w", err)
+	}
+
+	cfg.TrustProxyHeaders = os.Getenv("TRUST_PROXY_HEADERS") == "true"
+	cfg.RegistrationOpen = os.Getenv("REGISTRATION_OPEN") == "true"
+
+	if cfg.LoginRateLimit.Window, err = getenvDuration("LOGIN_RATE_WINDOW", 15*time.Minute); err != nil {
+		return Config{}, err
+	}
+	if cfg.LoginRateLimit.PerIP, err = getenvInt("LOGIN_RATE_PER_IP", 20); err != nil {
+		return Config{}, err
+	}
+	if cfg.LoginRateLimit.PerEmail, err = getenvInt("LOGIN_RATE_PER_EMAIL", 5); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.UserCacheTTL, err = getenvDuration("USER_CACHE_TTL", 30*time.Second); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.Mail.SMTPTimeout, err = getenvDuration("SMTP_TIMEOUT", 30*time.Second); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.Storage.URLTTL, err = getenvDuration("UPLOAD_URL_TTL", time.Hour); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.Storage.GCInterval, err = getenvDuration("UPLOAD_GC_INTERVAL", 6*time.Hour); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.Storage.GCGrace, err = getenvDuration("UPLOAD_GC_GRACE", 24*time.Hour); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.PageSize, err = getenvInt("PAGE_SIZE", 12); err != nil {
+		return Config{}, err
+	}
+	if cfg.PageSize > 100 {
+		return Config{}, fmt.Errorf("PAGE_SIZE must be at most 100, got %d", cfg.PageSize)
+	}
+
+	switch cfg.TokenStore {
+	case "postgres", "memory":
+	default:
+		return Config{}, fmt.Errorf("TOKEN_STORE must be postgres or memory, got %q", cfg.TokenStore)
+	}
+
+	switch cfg.SessionStore {
+	case "postgres", "memory":
+	default:
+		return Config{}, fmt.Errorf("SESSION_STORE must be postgres or memory, got %q", cfg.SessionStore)
+	}
+
+	switch cfg.Mail.Driver {
+	case "outbox":
+	case "smtp":
+		if cfg.Mail.SMTPHost == "" {
+			return Config{}, fmt.Errorf("SMTP_HOST must be set when MAIL_DRIVER=smtp")
+		}
+	default:
+		return Config{}, fmt.Errorf("MAIL_DRIVER must be smtp or outbox, got %q", cfg.Mail.Driver)
+	}
+
+	switch cfg.Storage.Backend {
+	case "local":
+	case "s3":
+		if cfg.Storage.S3Endpoint == "" || cfg.Storage.S3Bucket == "" {
+			return Config{}, fmt.Errorf("S3_ENDPOINT and S3_BUCKET must be set when STORAGE_BACKEND=s3")
+		}
+	default:
+		return Config{}, fmt.Errorf("STORAGE_BACKEND must be local or s3, got %q", cfg.Storage.Backend)
+	}
+
+	return cfg, nil
+}
+
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getenvInt(key string, fallback int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer, got %q", key, v)
+	}
+	return n, nil
+}
+
+func getenvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration like 15m, got %q", key, v)
+	}
+	return d, nil
+}
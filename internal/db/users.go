@@ -10,14 +10,36 @@ import (
 func GetUserByEmail(ctx context.Context, pool *pgxpool.Pool, email string) (*models.User, error) {
 	row := pool.QueryRow(
 		ctx,
-		`SELECT id, email, role, created_at
+		`SELECT id, email, role, created_at, deactivated_at, sessions_revoked_at
 		 FROM users
 		 WHERE email = $1`,
 		email,
 	)
 
 	var u models.User
-	err := row.Scan(&u.ID, &u.Email, &u.Role, &u.CreatedAt)
+	err := row.Scan(&u.ID, &u.Email, &u.Role, &u.CreatedAt, &u.DeactivatedAt, &u.SessionsRevokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// CreateUserIfNotExists inserts a user with the given role. If the email is
+// already registered the existing row is returned unchanged.
+func CreateUserIfNotExists(ctx context.Context, pool *pgxpool.Pool, email, role string) (*models.User, error) {
+	row := pool.QueryRow(
+		ctx,
+		`INSERT INTO users (email, role)
+		 VALUES ($1, $2)
+		 ON CONFLICT (email) DO UPDATE SET email = users.email
+		 RETURNING id, email, role, created_at, deactivated_at, sessions_revoked_at`,
+		email,
+		role,
+	)
+
+	var u models.User
+	err := row.Scan(&u.ID, &u.Email, &u.Role, &u.CreatedAt, &u.DeactivatedAt, &u.SessionsRevokedAt)
 	if err != nil {
 		return nil, err
 	}
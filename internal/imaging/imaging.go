@@ -0,0 +1,180 @@
+// Package imaging turns uploaded images into the files the app stores:
+// a clean re-encoded original and the resized variants pages load
+// instead of it.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Limits on the decoded size of an upload, so a small file that claims
+// huge dimensions cannot exhaust memory. At the pixel limit a decoded
+// photo takes about 100 MB once turned upright.
+const (
+	MaxPixels    = 24_000_000
+	MaxDimension = 12_000 // per side
+)
+
+// slot lets one image at a time be decoded, so parallel uploads, or a
+// gallery's worth in one request, can't multiply the memory a single
+// large image takes.
+var slot = make(chan struct{}, 1)
+
+var (
+	// ErrTooLarge is returned for images above MaxPixels or MaxDimension.
+	ErrTooLarge = errors.New("image dimensions too large")
+	// ErrUnsupported is returned for anything that isn't a JPEG or PNG.
+	ErrUnsupported = errors.New("only JPEG and PNG images are supported")
+)
+
+// formats maps the accepted formats, as named by image.Decode, to the
+// content type and key extension their files get.
+var formats = map[string]struct{ contentType, ext string }{
+	"jpeg": {"image/jpeg", ".jpg"},
+	"png":  {"image/png", ".png"},
+}
+
+// Variant is a resized copy of an upload, stored next to the original.
+type Variant struct {
+	Name  string
+	Width int // maximum width; smaller images are not upscaled
+}
+
+// Variants are generated for every upload, smallest first.
+var Variants = []Variant{
+	{Name: "thumb", Width: 320},
+	{Name: "card", Width: 640},
+	{Name: "full", Width: 1600},
+}
+
+// VariantKey returns the storage key of the named variant of the original
+// stored under key: projects/abc.png becomes projects/abc_card.png.
+func VariantKey(key, name string) string {
+	ext := path.Ext(key)
+	return strings.TrimSuffix(key, ext) + "_" + name + ext
+}
+
+// OriginalKey returns the key of the original a variant key belongs to,
+// or key itself if it is not a variant key.
+func OriginalKey(key string) string {
+	ext := path.Ext(key)
+	base := strings.TrimSuffix(key, ext)
+	for _, v := range Variants {
+		if original, ok := strings.CutSuffix(base, "_"+v.Name); ok {
+			return original + ext
+		}
+	}
+	return key
+}
+
+// Encoded is one variant ready to be stored.
+type Encoded struct {
+	Variant
+	Data []byte
+}
+
+// Processed is an upload ready to be stored.
+type Processed struct {
+	ContentType string // image/jpeg or image/png
+	Ext         string // .jpg or .png
+	// Original is the full-size image re-encoded from its pixels, so
+	// nothing of the uploaded file but the picture survives: no EXIF or
+	// GPS metadata, no comments, no trailing data.
+	Original []byte
+	Variants []Encoded
+}
+
+// Process fully decodes a JPEG or PNG, turns it upright according to its
+// EXIF orientation and re-encodes it in the same format, at full size and
+// at every variant width. Calls wait for each other.
+func Process(data []byte) (*Processed, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	f, ok := formats[format]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	if cfg.Width > MaxDimension || cfg.Height > MaxDimension || cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	slot <- struct{}{}
+	defer func() { <-slot }()
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if format == "jpeg" {
+		src = orient(src, jpegOrientation(data))
+	}
+
+	var original bytes.Buffer
+	if err := encode(&original, src, format, 90); err != nil {
+		return nil, fmt.Errorf("encode original: %w", err)
+	}
+
+	p := &Processed{
+		ContentType: f.contentType,
+		Ext:         f.ext,
+		Original:    original.Bytes(),
+		Variants:    make([]Encoded, 0, len(Variants)),
+	}
+	for _, v := range Variants {
+		var buf bytes.Buffer
+		if err := encode(&buf, scale(src, v.Width), format, 82); err != nil {
+			return nil, fmt.Errorf("encode %s variant: %w", v.Name, err)
+		}
+		p.Variants = append(p.Variants, Encoded{Variant: v, Data: buf.Bytes()})
+	}
+	return p, nil
+}
+
+// scale returns src shrunk to at most width pixels wide, keeping its
+// aspect ratio.
+func scale(src image.Image, width int) image.Image {
+	b := src.Bounds()
+	if b.Dx() <= width {
+		return src
+	}
+
+	height := max(1, b.Dy()*width/b.Dx())
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+	return dst
+}
+
+// encode writes img in format. The standard encoders write pixels only,
+// never metadata.
+func encode(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case "jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case "png":
+		return png.Encode(w, img)
+	default:
+		return ErrUnsupported
+	}
+}
+
+// Srcset returns the srcset attribute value listing every variant of the
+// original stored under key; url turns storage keys into URLs.
+func Srcset(key string, url func(string) string) string {
+	candidates := make([]string, len(Variants))
+	for i, v := range Variants {
+		candidates[i] = fmt.Sprintf("%s %dw", url(VariantKey(key, v.Name)), v.Width)
+	}
+	return strings.Join(candidates, ", ")
+}
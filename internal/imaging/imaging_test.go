@@ -0,0 +1,74 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+// pngHeader returns the start of a PNG claiming the given dimensions:
+// signature and IHDR, but no pixel data, so only DecodeConfig succeeds.
+func pngHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 2 // truecolor
+
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+	chunk := append([]byte("IHDR"), ihdr...)
+	buf.Write(chunk)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return buf.Bytes()
+}
+
+// jpegHeader returns a small JPEG whose frame header claims the given
+// dimensions, cut off after the length of its scan header.
+func jpegHeader(t *testing.T, width, height uint16) []byte {
+	t.Helper()
+	jpg := quadrants(t, 16, 16)
+	sof := bytes.Index(jpg, []byte{0xFF, 0xC0})
+	sos := bytes.Index(jpg, []byte{0xFF, 0xDA})
+	if sof < 0 || sos < 0 {
+		t.Fatal("no SOF0 or SOS marker in test JPEG")
+	}
+	out := append([]byte{}, jpg[:sos+4]...)
+	binary.BigEndian.PutUint16(out[sof+5:], height)
+	binary.BigEndian.PutUint16(out[sof+7:], width)
+	return out
+}
+
+// TestProcessTooLarge checks that oversized images are refused from their
+// header alone: the files carry no pixel data, so any attempt to decode
+// them would fail with a different error.
+func TestProcessTooLarge(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height uint32
+	}{
+		{"over MaxPixels", 6000, 6000},
+		{"over MaxDimension", MaxDimension + 1, 10},
+		{"both sides at MaxDimension", MaxDimension, MaxDimension},
+	}
+
+	for _, tt := range tests {
+		if _, err := Process(pngHeader(tt.width, tt.height)); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("PNG %s (%dx%d): err = %v, want ErrTooLarge", tt.name, tt.width, tt.height, err)
+		}
+		if _, err := Process(jpegHeader(t, uint16(tt.width), uint16(tt.height))); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("JPEG %s (%dx%d): err = %v, want ErrTooLarge", tt.name, tt.width, tt.height, err)
+		}
+	}
+
+	// Within the limits the same headers get as far as decoding.
+	if _, err := Process(pngHeader(100, 100)); err == nil || errors.Is(err, ErrTooLarge) {
+		t.Errorf("PNG 100x100 without pixel data: err = %v, want a decode error", err)
+	}
+	if _, err := Process(jpegHeader(t, 100, 100)); err == nil || errors.Is(err, ErrTooLarge) {
+		t.Errorf("JPEG 100x100 without scan data: err = %v, want a decode error", err)
+	}
+}
@@ -0,0 +1,140 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+)
+
+// jpegOrientation returns the EXIF orientation (1–8) of a JPEG, or 1 if
+// it has none. Cameras store photos as shot and record in this tag how to
+// turn them; once the metadata is stripped the pixels must be turned
+// instead.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			return 1 // image data or end of image: no APP1 segment
+		}
+
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && len(segment) >= 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+// tiffOrientation reads the Orientation tag from the first IFD of the
+// TIFF structure inside an EXIF segment.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int64(order.Uint32(tiff[4:]))
+	if ifd+2 > int64(len(tiff)) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for k := range entries {
+		e := int(ifd) + 2 + 12*k
+		if e+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[e:]) == 0x0112 {
+			if o := int(order.Uint16(tiff[e+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orient returns img turned upright for the given EXIF orientation. It
+// writes straight into the one full-size copy it returns.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 { // quarter turns swap width and height
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	at := pixelReader(img)
+
+	for y := range h {
+		for x := range w {
+			var dx, dy int
+			switch orientation {
+			case 2: // flip horizontally
+				dx, dy = w-1-x, y
+			case 3: // turn 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // flip vertically
+				dx, dy = x, h-1-y
+			case 5: // flip over the main diagonal
+				dx, dy = y, x
+			case 6: // turn 90° clockwise
+				dx, dy = h-1-y, x
+			case 7: // flip over the anti-diagonal
+				dx, dy = h-1-y, w-1-x
+			case 8: // turn 90° counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			c := at(b.Min.X+x, b.Min.Y+y)
+			i := dst.PixOffset(dx, dy)
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = c.R, c.G, c.B, c.A
+		}
+	}
+	return dst
+}
+
+// pixelReader returns a function reading the pixels of img, with fast
+// paths for the images the JPEG decoder returns for photos.
+func pixelReader(img image.Image) func(x, y int) color.RGBA {
+	switch src := img.(type) {
+	case *image.YCbCr:
+		return func(x, y int) color.RGBA {
+			yi, ci := src.YOffset(x, y), src.COffset(x, y)
+			r, g, b := color.YCbCrToRGB(src.Y[yi], src.Cb[ci], src.Cr[ci])
+			return color.RGBA{r, g, b, 0xff}
+		}
+	case *image.Gray:
+		return func(x, y int) color.RGBA {
+			v := src.Pix[src.PixOffset(x, y)]
+			return color.RGBA{v, v, v, 0xff}
+		}
+	default:
+		return func(x, y int) color.RGBA {
+			return color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+		}
+	}
+}
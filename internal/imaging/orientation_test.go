@@ -0,0 +1,185 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// exif returns an EXIF APP1 payload whose first IFD holds only the
+// Orientation tag.
+func exif(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8) // first IFD right after the header
+	order.PutUint16(tiff[8:], 1) // one entry
+	order.PutUint16(tiff[10:], 0x0112)
+	order.PutUint16(tiff[12:], 3) // SHORT
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+	return append([]byte("Exif\x00\x00"), tiff...)
+}
+
+// withSegment inserts an APPn segment with payload right after the SOI
+// marker of a JPEG. size overrides the segment length if not zero.
+func withSegment(jpg []byte, marker byte, payload []byte, size int) []byte {
+	if size == 0 {
+		size = len(payload) + 2
+	}
+	seg := []byte{0xFF, marker, byte(size >> 8), byte(size)}
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, seg...)
+	out = append(out, payload...)
+	return append(out, jpg[2:]...)
+}
+
+// Quadrant colors of the test image, far enough apart to survive JPEG.
+var (
+	red   = color.RGBA{0xff, 0, 0, 0xff}
+	green = color.RGBA{0, 0xff, 0, 0xff}
+	blue  = color.RGBA{0, 0, 0xff, 0xff}
+	white = color.RGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+// quadrants returns a w×h JPEG that is red top left, green top right,
+// blue bottom left and white bottom right.
+func quadrants(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			c := red
+			switch {
+			case x >= w/2 && y < h/2:
+				c = green
+			case x < w/2 && y >= h/2:
+				c = blue
+			case x >= w/2 && y >= h/2:
+				c = white
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	jpg := quadrants(t, 16, 8)
+
+	for o := uint16(1); o <= 8; o++ {
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			if got := jpegOrientation(withSegment(jpg, 0xE1, exif(order, o), 0)); got != int(o) {
+				t.Errorf("orientation %d (%v): got %d", o, order, got)
+			}
+		}
+	}
+
+	valid := exif(binary.BigEndian, 6)
+	badOrder := append([]byte{}, valid...)
+	copy(badOrder[6:], "XX")
+	farIFD := append([]byte{}, valid...)
+	binary.BigEndian.PutUint32(farIFD[10:], 1<<30)
+	manyEntries := append([]byte{}, valid...)
+	binary.BigEndian.PutUint16(manyEntries[14:], 1000)
+	binary.BigEndian.PutUint16(manyEntries[16:], 0x0100) // not Orientation
+
+	// Broken or missing metadata must read as "upright", never panic.
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not a JPEG", []byte("\x89PNG\r\n\x1a\n")},
+		{"SOI only", jpg[:2]},
+		{"no APP1", jpg},
+		{"orientation 0", withSegment(jpg, 0xE1, exif(binary.BigEndian, 0), 0)},
+		{"orientation 9", withSegment(jpg, 0xE1, exif(binary.BigEndian, 9), 0)},
+		{"APP1 length past the end", withSegment(jpg, 0xE1, valid, 0xFFFF)[:40]},
+		{"APP1 length below 2", withSegment(jpg, 0xE1, valid, 1)},
+		{"truncated APP1", withSegment(jpg, 0xE1, valid, 0)[:2+4+len(valid)/2]},
+		{"APP1 without Exif header", withSegment(jpg, 0xE1, valid[6:], 0)},
+		{"short TIFF header", withSegment(jpg, 0xE1, valid[:6+4], 0)},
+		{"unknown byte order", withSegment(jpg, 0xE1, badOrder, 0)},
+		{"IFD offset out of range", withSegment(jpg, 0xE1, farIFD, 0)},
+		{"entry count past the end", withSegment(jpg, 0xE1, manyEntries, 0)},
+		{"IFD cut short", withSegment(jpg, 0xE1, valid[:6+8+2+6], 0)},
+		{"Exif in APP2", withSegment(jpg, 0xE2, valid, 0)},
+	}
+	for _, tt := range tests {
+		if got := jpegOrientation(tt.data); got != 1 {
+			t.Errorf("%s: got %d, want 1", tt.name, got)
+		}
+	}
+}
+
+func TestProcessOrientation(t *testing.T) {
+	const w, h = 32, 16
+	jpg := quadrants(t, w, h)
+
+	// Which of the stored quadrants must end up top left and top right of
+	// the upright image, per the EXIF definition of each orientation.
+	tests := []struct {
+		orientation       uint16
+		topLeft, topRight color.RGBA
+	}{
+		{1, red, green},
+		{2, green, red},   // mirrored
+		{3, white, blue},  // turned 180°
+		{4, blue, white},  // flipped
+		{5, red, blue},    // transposed
+		{6, blue, red},    // needs 90° clockwise
+		{7, white, green}, // transversed
+		{8, green, white}, // needs 90° counter-clockwise
+	}
+
+	for _, tt := range tests {
+		p, err := Process(withSegment(jpg, 0xE1, exif(binary.LittleEndian, tt.orientation), 0))
+		if err != nil {
+			t.Fatalf("orientation %d: %v", tt.orientation, err)
+		}
+		img, err := jpeg.Decode(bytes.NewReader(p.Original))
+		if err != nil {
+			t.Fatalf("orientation %d: decode result: %v", tt.orientation, err)
+		}
+
+		wantW, wantH := w, h
+		if tt.orientation >= 5 {
+			wantW, wantH = h, w
+		}
+		b := img.Bounds()
+		if b.Dx() != wantW || b.Dy() != wantH {
+			t.Errorf("orientation %d: size %dx%d, want %dx%d", tt.orientation, b.Dx(), b.Dy(), wantW, wantH)
+			continue
+		}
+		if jpegOrientation(p.Original) != 1 {
+			t.Errorf("orientation %d: result still carries an orientation", tt.orientation)
+		}
+
+		if got := img.At(2, 2); !near(got, tt.topLeft) {
+			t.Errorf("orientation %d: top left is %v, want %v", tt.orientation, got, tt.topLeft)
+		}
+		if got := img.At(wantW-3, 2); !near(got, tt.topRight) {
+			t.Errorf("orientation %d: top right is %v, want %v", tt.orientation, got, tt.topRight)
+		}
+	}
+}
+
+// near reports whether c is within JPEG noise of want.
+func near(c color.Color, want color.RGBA) bool {
+	got := color.RGBAModel.Convert(c).(color.RGBA)
+	d := func(a, b uint8) int { return max(int(a), int(b)) - min(int(a), int(b)) }
+	return d(got.R, want.R) < 48 && d(got.G, want.G) < 48 && d(got.B, want.B) < 48
+}
@@ -0,0 +1,47 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// OutboxMailer writes every message as an .eml file into Dir instead of
+// sending it. Meant for local development: open the file to follow links.
+type OutboxMailer struct {
+	Dir  string
+	From string
+}
+
+func (m *OutboxMailer) Send(ctx context.Context, msg Message) error {
+	raw, err := msg.bytes(m.From)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return err
+	}
+
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(msg.To)
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), recipient)
+	path := filepath.Join(m.Dir, name)
+
+	if err := os.WriteFile(path, raw, 0600); err != nil {
+		return err
+	}
+
+	slog.InfoContext(
+		ctx,
+		"email written to outbox",
+		"event.category", "mail",
+		"event.type", "outbox",
+		"file.path", path,
+	)
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"path/filepath"
+	texttemplate "text/template"
+)
+
+// Templates renders emails from pairs of files in one directory:
+// <name>.txt for the plain-text part and <name>.html for the HTML part.
+type Templates struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+func ParseTemplates(dir string) (*Templates, error) {
+	text, err := texttemplate.ParseGlob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	html, err := htmltemplate.ParseGlob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Templates{text: text, html: html}, nil
+}
+
+// Render builds a Message for the named template pair.
+func (t *Templates) Render(name, to, subject string, data any) (Message, error) {
+	var text, html bytes.Buffer
+
+	if err := t.text.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return Message{}, err
+	}
+	if err := t.html.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To:      to,
+		Subject: subject,
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
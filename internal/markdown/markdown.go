@@ -0,0 +1,84 @@
+// Package markdown renders user-written Markdown to sanitized HTML.
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"html/template"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Raw HTML in the source is dropped by goldmark (no WithUnsafe); the
+// sanitizer below is the actual security boundary.
+var md = goldmark.New(
+	goldmark.WithExtensions(extension.Strikethrough, extension.Linkify),
+	goldmark.WithParserOptions(
+		parser.WithASTTransformers(util.Prioritized(headingShift{}, 100)),
+	),
+	// Single line breaks are kept, as authors expect from a plain textarea.
+	goldmark.WithRendererOptions(goldmarkhtml.WithHardWraps()),
+)
+
+var policy = newPolicy()
+
+// newPolicy allow-lists the elements Markdown produces for text, lists,
+// quotes, code and links. Images and raw HTML are not allowed. Links must
+// be http(s) or mailto and get rel="nofollow".
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements(
+		"p", "br", "hr",
+		"strong", "em", "del", "code", "pre", "blockquote",
+		"ul", "ol", "li",
+		"h2", "h3", "h4", "h5", "h6",
+	)
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	return p
+}
+
+// Render converts Markdown to sanitized HTML that is safe to embed in a page.
+func Render(src string) template.HTML {
+	return template.HTML(policy.Sanitize(toHTML(src)))
+}
+
+// PlainText returns the text a reader sees once src is rendered, with
+// whitespace collapsed. It is used for excerpts and length limits.
+func PlainText(src string) string {
+	text := bluemonday.StrictPolicy().Sanitize(toHTML(src))
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+func toHTML(src string) string {
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(src), &buf); err != nil {
+		// goldmark only fails on writer errors, which a bytes.Buffer doesn't produce.
+		return html.EscapeString(src)
+	}
+	return buf.String()
+}
+
+// headingShift moves every heading one level down, so a "# Title" in a
+// description does not compete with the page's own <h1>.
+type headingShift struct{}
+
+func (headingShift) Transform(doc *ast.Document, _ text.Reader, _ parser.Context) {
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if h, ok := n.(*ast.Heading); ok && entering && h.Level < 6 {
+			h.Level++
+		}
+		return ast.WalkContinue, nil
+	})
+}
@@ -2,13 +2,66 @@ package models
 
 import "time"
 
+// Description limits. Descriptions are stored as Markdown source. What
+// readers see is capped at the length plain-text descriptions always had;
+// the source gets extra room for markup such as link URLs.
+const (
+	MaxDescriptionText   = 1000
+	MaxDescriptionSource = 4000
+)
+
+// ProjectStatus is where a project is in the moderation workflow.
+type ProjectStatus string
+
+const (
+	StatusDraft    ProjectStatus = "draft"
+	StatusPending  ProjectStatus = "pending"
+	StatusApproved ProjectStatus = "approved"
+	StatusRejected ProjectStatus = "rejected"
+	StatusArchived ProjectStatus = "archived"
+)
+
 type Project struct {
 	ID          int
 	Title       string
 	Description string
-	ImagePath   *string // pointer allows nil
-	AuthorEmail string
-	Approved    bool
-	CreatedAt   time.Time
-	DeletedAt   time.Time
+	ImagePath   *string // storage key of the cover image, nil if there is none
+	// ImageHasVariants is set when the cover was stored with resized variants.
+	ImageHasVariants bool
+	AuthorEmail      string
+	Status           ProjectStatus
+	// RejectionReason is the moderator's feedback while Status is rejected.
+	RejectionReason string
+	CreatedAt       time.Time
+	DeletedAt       time.Time // set while archived
+	// EditedAt and EditedFields describe author edits made since the
+	// project was last approved; both are cleared on approval.
+	EditedAt     *time.Time
+	EditedFields []string
+	// ApprovedRevisionID points at the revision that was live when the
+	// project was last approved; nil if it never was.
+	ApprovedRevisionID *int
+	// PublishAt delays publication of an approved project; nil means
+	// it is public as soon as it is approved.
+	PublishAt *time.Time
+	// Tags is only filled by queries that load them, curated tags first.
+	Tags []Tag
+	// Images is the gallery in display order. Only single-project
+	// queries fill it; lists use ImagePath, which is the first image.
+	Images []ProjectImage
+}
+
+// HasTag reports whether the project carries the tag with the given slug.
+func (p Project) HasTag(slug string) bool {
+	for _, t := range p.Tags {
+		if t.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// Scheduled reports whether the project is approved but not public yet.
+func (p Project) Scheduled() bool {
+	return p.Status == StatusApproved && p.PublishAt != nil && p.PublishAt.After(time.Now())
 }
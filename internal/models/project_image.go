@@ -0,0 +1,22 @@
+package models
+
+// Gallery limits.
+const (
+	MaxImagesPerProject   = 8
+	MaxImageAltLength     = 200
+	MaxImageCaptionLength = 300
+)
+
+// ProjectImage is one picture in a project's gallery.
+type ProjectImage struct {
+	ID        int
+	ProjectID int
+	Path      string // storage key, e.g. projects/<uuid>.png
+	// AltText describes the picture for screen readers; Caption is shown
+	// below it.
+	AltText  string
+	Caption  string
+	Position int // 1-based; the first image is the cover
+	// HasVariants is set for uploads stored with resized variants.
+	HasVariants bool
+}
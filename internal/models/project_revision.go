@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ProjectRevision is a snapshot of a project's content, written on every
+// create and edit.
+type ProjectRevision struct {
+	ID          int
+	ProjectID   int
+	Title       string
+	Description string
+	ImagePath   string // the cover; empty if there was none
+	Tags        []string
+	// Images is the gallery, loaded for single revisions only.
+	Images      []ProjectImage
+	AuthorEmail string
+	CreatedAt   time.Time
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MaxTagsPerProject and MaxTagLength bound what authors can attach.
+const (
+	MaxTagsPerProject = 10
+	MaxTagLength      = 30
+)
+
+// Tag labels projects. Curated tags form the admin-maintained category
+// vocabulary; free tags are created on the fly from what authors type.
+type Tag struct {
+	ID          int
+	Slug        string
+	Name        string
+	Curated     bool
+	Description string
+	// ProjectCount is the number of public projects with the tag; only
+	// filled by listings that need it.
+	ProjectCount int
+}
+
+// TagSlug turns a tag name into its URL form: lower case letters and
+// digits separated by single dashes. It returns "" for names without any
+// letter or digit.
+func TagSlug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		default:
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// CleanTagNames trims and collapses whitespace in tag names and drops
+// empty names and names with the same slug as an earlier one.
+func CleanTagNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	var cleaned []string
+	for _, name := range names {
+		name = strings.Join(strings.Fields(name), " ")
+		slug := TagSlug(name)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		cleaned = append(cleaned, name)
+	}
+	return cleaned
+}
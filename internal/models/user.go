@@ -2,9 +2,40 @@ package models
 
 import "time"
 
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Roles lists the roles an admin can assign.
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+func IsValidRole(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
 type User struct {
-	ID        int
-	Email     string
-	Role      string
-	CreatedAt time.Time
+	ID            int
+	Email         string
+	Role          string
+	CreatedAt     time.Time
+	DeactivatedAt *time.Time // nil while the account is active
+	// SessionsRevokedAt ends every session that logged in before it.
+	SessionsRevokedAt *time.Time
+}
+
+func (u User) Active() bool {
+	return u.DeactivatedAt == nil
+}
+
+// SessionRevoked reports whether a session that logged in at loggedInAt
+// has been revoked since.
+func (u User) SessionRevoked(loggedInAt time.Time) bool {
+	return u.SessionsRevokedAt != nil && loggedInAt.Before(*u.SessionsRevokedAt)
 }
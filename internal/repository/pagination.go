@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/janphilippgutt/casproject/internal/models"
+)
+
+// Sort is the order of a paginated project list.
+type Sort string
+
+const (
+	SortNewest Sort = "newest"
+	SortOldest Sort = "oldest"
+	SortTitle  Sort = "title"
+)
+
+// Sorts lists the supported orders in the order they are offered in the UI.
+var Sorts = []Sort{SortNewest, SortOldest, SortTitle}
+
+// ParseSort returns the Sort named by s, or fallback for anything else.
+func ParseSort(s string, fallback Sort) Sort {
+	for _, sort := range Sorts {
+		if string(sort) == s {
+			return sort
+		}
+	}
+	return fallback
+}
+
+// MaxPageSize caps PageRequest.Limit.
+const MaxPageSize = 100
+
+// ErrInvalidCursor is returned for a cursor that was not produced by a
+// ProjectPage of the same sort order.
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// PageRequest selects one page of a list. At most one of After and Before
+// is set; with neither, the first page is returned.
+type PageRequest struct {
+	Sort  Sort
+	Limit int
+	// After is ProjectPage.Next of the previous page.
+	After string
+	// Before is ProjectPage.Prev of the following page.
+	Before string
+}
+
+// ProjectPage is one page of projects plus cursors to its neighbours.
+// A cursor is empty when there is no page in that direction.
+type ProjectPage struct {
+	Projects []models.Project
+	Next     string
+	Prev     string
+}
+
+// cursor is the sort key and ID of the row a page starts after or ends
+// before. Exactly one of Time and Text is set, depending on the sort.
+type cursor struct {
+	Sort Sort       `json:"o"`
+	Time *time.Time `json:"t,omitempty"`
+	Text *string    `json:"s,omitempty"`
+	ID   int        `json:"id"`
+}
+
+func encodeCursor(sort Sort, key any, id int) (string, error) {
+	c := cursor{Sort: sort, ID: id}
+	switch k := key.(type) {
+	case time.Time:
+		c.Time = &k
+	case string:
+		c.Text = &k
+	default:
+		return "", fmt.Errorf("unsupported sort key type %T", key)
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(s string, sort Sort) (any, int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Sort != sort {
+		return nil, 0, ErrInvalidCursor
+	}
+
+	switch {
+	case sort == SortTitle && c.Text != nil:
+		return *c.Text, c.ID, nil
+	case sort != SortTitle && c.Time != nil:
+		return *c.Time, c.ID, nil
+	default:
+		return nil, 0, ErrInvalidCursor
+	}
+}
+
+// projectColumns is the column list listPage selects.
+const projectColumns = `
+	id, title, project_description, image_path, image_has_variants, author_email, status, rejection_reason,
+	created_at, deleted_at, edited_at, edited_fields, approved_revision_id, publish_at`
+
+// listPage returns one page of the projects matching where (a SQL boolean
+// expression over projects using placeholders $1..$len(args)). timeKey is
+// the timestamp expression the newest/oldest orders use; the title order
+// sorts case-insensitively by title. Ties are broken by ID, which keeps
+// the order total so no row is skipped or repeated between pages.
+func (r *ProjectRepository) listPage(
+	ctx context.Context,
+	where string,
+	args []any,
+	timeKey string,
+	req PageRequest,
+) (ProjectPage, error) {
+	if req.Sort == "" {
+		req.Sort = SortNewest
+	}
+
+	key, desc := timeKey, true
+	switch req.Sort {
+	case SortOldest:
+		desc = false
+	case SortTitle:
+		key, desc = "lower(title)", false
+	}
+
+	limit := req.Limit
+	if limit <= 0 || limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	// Walking backwards means reading the rows before the cursor in
+	// reverse order and flipping them afterwards.
+	backward := req.Before != ""
+	token := req.After
+	if backward {
+		token = req.Before
+	}
+
+	ascending := desc == backward
+	cmp, dir := ">", "ASC"
+	if !ascending {
+		cmp, dir = "<", "DESC"
+	}
+
+	query := `SELECT ` + projectColumns + `, ` + key + ` AS sort_key FROM projects WHERE (` + where + `)`
+	if token != "" {
+		cursorKey, cursorID, err := decodeCursor(token, req.Sort)
+		if err != nil {
+			return ProjectPage{}, err
+		}
+		args = append(args, cursorKey, cursorID)
+		query += ` AND (` + key + `, id) ` + cmp +
+			` ($` + strconv.Itoa(len(args)-1) + `, $` + strconv.Itoa(len(args)) + `)`
+	}
+	args = append(args, limit+1)
+	query += ` ORDER BY ` + key + ` ` + dir + `, id ` + dir + ` LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return ProjectPage{}, err
+	}
+	defer rows.Close()
+
+	var projects []models.Project
+	var keys []any
+	for rows.Next() {
+		var p models.Project
+		var deletedAt *time.Time
+		var sortKey any
+		if err := rows.Scan(
+			&p.ID,
+			&p.Title,
+			&p.Description,
+			&p.ImagePath,
+			&p.ImageHasVariants,
+			&p.AuthorEmail,
+			&p.Status,
+			&p.RejectionReason,
+			&p.CreatedAt,
+			&deletedAt,
+			&p.EditedAt,
+			&p.EditedFields,
+			&p.ApprovedRevisionID,
+			&p.PublishAt,
+			&sortKey,
+		); err != nil {
+			return ProjectPage{}, err
+		}
+		if deletedAt != nil {
+			p.DeletedAt = *deletedAt
+		}
+		projects = append(projects, p)
+		keys = append(keys, sortKey)
+	}
+	if err := rows.Err(); err != nil {
+		return ProjectPage{}, err
+	}
+
+	more := len(projects) > limit
+	if more {
+		projects, keys = projects[:limit], keys[:limit]
+	}
+	if backward {
+		for i, j := 0, len(projects)-1; i < j; i, j = i+1, j-1 {
+			projects[i], projects[j] = projects[j], projects[i]
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+
+	if err := r.attachTags(ctx, projects); err != nil {
+		return ProjectPage{}, err
+	}
+
+	page := ProjectPage{Projects: projects}
+	if len(projects) == 0 {
+		return page, nil
+	}
+
+	// Forward, there is a previous page whenever we started from a cursor;
+	// backward, there is always a next page, the one we came from.
+	hasNext, hasPrev := more, token != ""
+	if backward {
+		hasNext, hasPrev = true, more
+	}
+
+	last := len(projects) - 1
+	if hasNext {
+		if page.Next, err = encodeCursor(req.Sort, keys[last], projects[last].ID); err != nil {
+			return ProjectPage{}, err
+		}
+	}
+	if hasPrev {
+		if page.Prev, err = encodeCursor(req.Sort, keys[0], projects[0].ID); err != nil {
+			return ProjectPage{}, err
+		}
+	}
+
+	return page, nil
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/janphilippgutt/casproject/internal/models"
+)
+
+// listImages returns the gallery of a project in display order.
+func (r *ProjectRepository) listImages(ctx context.Context, projectID int) ([]models.ProjectImage, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT id, project_id, path, alt_text, caption, position, has_variants
+		FROM project_images
+		WHERE project_id = $1
+		ORDER BY position, id
+	`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var images []models.ProjectImage
+	for rows.Next() {
+		var img models.ProjectImage
+		if err := rows.Scan(
+			&img.ID,
+			&img.ProjectID,
+			&img.Path,
+			&img.AltText,
+			&img.Caption,
+			&img.Position,
+			&img.HasVariants,
+		); err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+
+	return images, rows.Err()
+}
+
+// listRevisionImages returns the gallery saved with a revision in display
+// order.
+func (r *ProjectRepository) listRevisionImages(ctx context.Context, revisionID int) ([]models.ProjectImage, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT path, alt_text, caption, position, has_variants
+		FROM project_revision_images
+		WHERE revision_id = $1
+		ORDER BY position
+	`, revisionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var images []models.ProjectImage
+	for rows.Next() {
+		var img models.ProjectImage
+		if err := rows.Scan(
+			&img.Path,
+			&img.AltText,
+			&img.Caption,
+			&img.Position,
+			&img.HasVariants,
+		); err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+
+	return images, rows.Err()
+}
+
+// ImageIsPublic reports whether the image stored under key belongs to a
+// project the public can see: approved and past its publish_at, if set.
+func (r *ProjectRepository) ImageIsPublic(ctx context.Context, key string) (bool, error) {
+	var public bool
+	err := r.DB.QueryRow(ctx, `
+		SELECT EXISTS (
+		    SELECT 1
+		    FROM project_images i
+		    JOIN projects p ON p.id = i.project_id
+		    WHERE i.path = $1
+		      AND p.status = 'approved'
+		      AND (p.publish_at IS NULL OR p.publish_at <= NOW())
+		)
+	`, key).Scan(&public)
+	return public, err
+}
+
+// ImageKeys returns the storage keys of the images the upload GC keeps:
+// those of project galleries and covers, and those of each project's
+// approved and latest revision, which review compares. Images only older
+// revisions showed are left to the GC; history then lacks their pictures.
+func (r *ProjectRepository) ImageKeys(ctx context.Context) (map[string]bool, error) {
+	rows, err := r.DB.Query(ctx, `
+		WITH kept AS (
+		    SELECT approved_revision_id AS id FROM projects WHERE approved_revision_id IS NOT NULL
+		    UNION
+		    SELECT MAX(id) FROM project_revisions GROUP BY project_id
+		)
+		SELECT path FROM project_images
+		UNION
+		SELECT image_path FROM projects WHERE image_path IS NOT NULL
+		UNION
+		SELECT ri.path FROM project_revision_images ri JOIN kept ON kept.id = ri.revision_id
+		UNION
+		SELECT r.image_path FROM project_revisions r JOIN kept ON kept.id = r.id WHERE r.image_path <> ''
+	`)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		keys[p] = true
+	}
+	return keys, nil
+}
+
+// setProjectImages makes images, in order, the gallery of a project.
+// Images with an ID keep their row and get the new alt text, caption and
+// position; images without one are inserted; the project's other images
+// are dropped from the gallery. Their files stay while the approved or
+// latest revision shows them; after that the upload GC removes them (see
+// ImageKeys). The cover (projects.image_path) follows the first image.
+func setProjectImages(ctx context.Context, tx pgx.Tx, projectID int, images []models.ProjectImage) error {
+	rows, err := tx.Query(ctx, `
+		SELECT id
+		FROM project_images
+		WHERE project_id = $1
+		FOR UPDATE
+	`, projectID)
+	if err != nil {
+		return err
+	}
+	current := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		current[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	kept := make(map[int]bool, len(images))
+	for _, img := range images {
+		if img.ID == 0 {
+			continue
+		}
+		if !current[img.ID] {
+			return fmt.Errorf("image %d does not belong to project %d", img.ID, projectID)
+		}
+		kept[img.ID] = true
+	}
+
+	var removedIDs []int
+	for id := range current {
+		if !kept[id] {
+			removedIDs = append(removedIDs, id)
+		}
+	}
+	if len(removedIDs) > 0 {
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM project_images
+			WHERE id = ANY($1)
+		`, removedIDs); err != nil {
+			return err
+		}
+	}
+
+	for i, img := range images {
+		if img.ID == 0 {
+			_, err = tx.Exec(ctx, `
+				INSERT INTO project_images (project_id, path, alt_text, caption, position, has_variants)
+				VALUES ($1, $2, $3, $4, $5, $6)
+			`, projectID, img.Path, img.AltText, img.Caption, i+1, img.HasVariants)
+		} else {
+			_, err = tx.Exec(ctx, `
+				UPDATE project_images
+				SET alt_text = $2,
+				    caption = $3,
+				    position = $4
+				WHERE id = $1
+			`, img.ID, img.AltText, img.Caption, i+1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE projects
+		SET image_path = (
+		        SELECT path
+		        FROM project_images
+		        WHERE project_id = $1
+		        ORDER BY position, id
+		        LIMIT 1
+		    ),
+		    image_has_variants = COALESCE((
+		        SELECT has_variants
+		        FROM project_images
+		        WHERE project_id = $1
+		        ORDER BY position, id
+		        LIMIT 1
+		    ), FALSE)
+		WHERE id = $1
+	`, projectID); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/janphilippgutt/casproject/internal/markdown"
+	"github.com/janphilippgutt/casproject/internal/models"
+)
+
+// Markers around matched words in ProjectSearchResult.Snippet. They are
+// control characters so they can't clash with HTML or with what users type;
+// callers escape the snippet and then turn the markers into markup.
+const (
+	HighlightStart = "\x02"
+	HighlightStop  = "\x03"
+)
+
+// searchLimit caps the number of search results returned.
+const searchLimit = 50
+
+// ProjectSearchResult is a project matched by Search.
+type ProjectSearchResult struct {
+	models.Project
+	Rank float32
+	// Snippet is an excerpt of the description's plain text with matches
+	// wrapped in HighlightStart and HighlightStop.
+	Snippet string
+}
+
+// Search runs a full-text query over title and description of projects in
+// one of the given statuses, best match first. query uses web search
+// syntax ("quoted phrases", -excluded, or). Approved projects that are
+// scheduled for later are only matched once they are public.
+func (r *ProjectRepository) Search(
+	ctx context.Context,
+	query string,
+	statuses ...models.ProjectStatus,
+) ([]ProjectSearchResult, error) {
+	headlineOpts := "StartSel=" + HighlightStart + ", StopSel=" + HighlightStop +
+		", MaxFragments=2, MinWords=8, MaxWords=30, FragmentDelimiter=\" … \""
+
+	statusNames := make([]string, len(statuses))
+	for i, st := range statuses {
+		statusNames[i] = string(st)
+	}
+
+	rows, err := r.DB.Query(ctx, `
+		SELECT p.id, p.title, p.project_description, p.image_path, p.image_has_variants, p.author_email,
+		       p.status, p.rejection_reason, p.created_at, p.edited_at, p.edited_fields,
+		       p.publish_at,
+		       ts_rank(p.search_vector, q) AS rank
+		FROM projects p, websearch_to_tsquery('english', $1) AS q
+		WHERE p.search_vector @@ q
+		  AND p.status = ANY($2)
+		  AND (p.status <> 'approved' OR p.publish_at IS NULL OR p.publish_at <= NOW())
+		ORDER BY rank DESC, p.created_at DESC
+		LIMIT $3
+	`, query, statusNames, searchLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ProjectSearchResult
+	for rows.Next() {
+		var res ProjectSearchResult
+		if err := rows.Scan(
+			&res.ID,
+			&res.Title,
+			&res.Description,
+			&res.ImagePath,
+			&res.ImageHasVariants,
+			&res.AuthorEmail,
+			&res.Status,
+			&res.RejectionReason,
+			&res.CreatedAt,
+			&res.EditedAt,
+			&res.EditedFields,
+			&res.PublishAt,
+			&res.Rank,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Descriptions are Markdown, so the snippets are cut from their plain
+	// text, the way listings show excerpts.
+	texts := make([]string, len(results))
+	for i := range results {
+		texts[i] = markdown.PlainText(results[i].Description)
+	}
+	rows, err = r.DB.Query(ctx, `
+		SELECT ts_headline('english', t.text, websearch_to_tsquery('english', $1), $3)
+		FROM unnest($2::text[]) WITH ORDINALITY AS t(text, n)
+		ORDER BY t.n
+	`, query, texts, headlineOpts)
+	if err != nil {
+		return nil, err
+	}
+	snippets, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Snippet = snippets[i]
+	}
+
+	projects := make([]models.Project, len(results))
+	for i := range results {
+		projects[i] = results[i].Project
+	}
+	if err := r.attachTags(ctx, projects); err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Tags = projects[i].Tags
+	}
+
+	return results, nil
+}
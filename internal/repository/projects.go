@@ -5,6 +5,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -15,145 +16,161 @@ type ProjectRepository struct {
 	DB *pgxpool.Pool
 }
 
-func (r *ProjectRepository) ListApproved(ctx context.Context) ([]models.Project, error) {
-	rows, err := r.DB.Query(ctx, `
-		SELECT id, title, project_description, image_path, author_email, approved, created_at
-		FROM projects
-		WHERE approved = true
-		AND deleted_at IS NULL
-		ORDER BY created_at DESC
-	`)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var projects []models.Project
-
-	for rows.Next() {
-		var p models.Project
-		if err := rows.Scan(
-			&p.ID,
-			&p.Title,
-			&p.Description,
-			&p.ImagePath,
-			&p.AuthorEmail,
-			&p.Approved,
-			&p.CreatedAt,
-		); err != nil {
-			return nil, err
-		}
+// ListApproved returns a page of the public projects: approved and past
+// their publish_at, if one is set. Newest means most recently published.
+func (r *ProjectRepository) ListApproved(ctx context.Context, req PageRequest) (ProjectPage, error) {
+	return r.listPage(ctx,
+		`status = 'approved' AND (publish_at IS NULL OR publish_at <= NOW())`, nil,
+		"COALESCE(publish_at, created_at)", req)
+}
 
-		projects = append(projects, p)
-	}
+// ListApprovedByTag is ListApproved restricted to projects tagged slug.
+func (r *ProjectRepository) ListApprovedByTag(ctx context.Context, slug string, req PageRequest) (ProjectPage, error) {
+	return r.listPage(ctx,
+		`status = 'approved' AND (publish_at IS NULL OR publish_at <= NOW())
+		 AND id IN (
+		     SELECT pt.project_id
+		     FROM project_tags pt
+		     JOIN tags t ON t.id = pt.tag_id
+		     WHERE t.slug = $1
+		 )`, []any{slug},
+		"COALESCE(publish_at, created_at)", req)
+}
 
-	return projects, rows.Err()
+// ListScheduled returns a page of approved projects whose publish_at is
+// still in the future, ordered by publication time.
+func (r *ProjectRepository) ListScheduled(ctx context.Context, req PageRequest) (ProjectPage, error) {
+	return r.listPage(ctx,
+		`status = 'approved' AND publish_at > NOW()`, nil,
+		"publish_at", req)
 }
 
-func (r *ProjectRepository) ListUnapproved(ctx context.Context) ([]models.Project, error) {
-	rows, err := r.DB.Query(ctx, `
-		SELECT id, title, project_description, image_path, author_email, approved, created_at
-		FROM projects
-		WHERE approved = false
-		AND deleted_at IS NULL
-		ORDER BY created_at ASC
-	`)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// ListPending returns a page of the moderation queue.
+func (r *ProjectRepository) ListPending(ctx context.Context, req PageRequest) (ProjectPage, error) {
+	return r.listPage(ctx, `status = $1`, []any{string(models.StatusPending)}, "created_at", req)
+}
 
-	var projects []models.Project
-	for rows.Next() {
-		var p models.Project
-		if err := rows.Scan(
-			&p.ID,
-			&p.Title,
-			&p.Description,
-			&p.ImagePath,
-			&p.AuthorEmail,
-			&p.Approved,
-			&p.CreatedAt,
-		); err != nil {
-			return nil, err
-		}
-		projects = append(projects, p)
-	}
-	return projects, rows.Err()
+// ListRejected returns a page of rejected projects with their rejection reasons.
+func (r *ProjectRepository) ListRejected(ctx context.Context, req PageRequest) (ProjectPage, error) {
+	return r.listPage(ctx, `status = $1`, []any{string(models.StatusRejected)}, "created_at", req)
 }
 
+// Create inserts a project together with its gallery, its first revision
+// and its tags. status is either StatusDraft, which keeps it private to the
+// author, or StatusPending, which submits it for review.
 func (r *ProjectRepository) Create(
 	ctx context.Context,
 	title string,
 	description string,
-	imagePath string,
+	images []models.ProjectImage,
 	authorEmail string,
+	status models.ProjectStatus,
+	tags []string,
 ) error {
-	_, err := r.DB.Exec(ctx, `
-		INSERT INTO projects (title, project_description, image_path, author_email, approved)
-		VALUES ($1, $2, $3, $4, false)
+	if status != models.StatusDraft && status != models.StatusPending {
+		return errors.New("new projects must be drafts or pending")
+	}
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var id int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO projects (title, project_description, author_email, status)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
 	`,
 		title,
 		description,
-		imagePath,
 		authorEmail,
-	)
+		status,
+	).Scan(&id)
+	if err != nil {
+		return err
+	}
 
-	return err
-}
+	if err := setProjectImages(ctx, tx, id, images); err != nil {
+		return err
+	}
 
-func (r *ProjectRepository) ListArchived(ctx context.Context) ([]models.Project, error) {
-	rows, err := r.DB.Query(ctx, `
-		SELECT id, title, project_description, image_path, author_email, approved, created_at, deleted_at
-		FROM projects
-		WHERE deleted_at IS NOT NULL
-		ORDER BY deleted_at DESC
-	`)
-	if err != nil {
-		return nil, err
+	if err := setProjectTags(ctx, tx, id, tags); err != nil {
+		return err
 	}
-	defer rows.Close()
 
-	var projects []models.Project
-	for rows.Next() {
-		var p models.Project
-		if err := rows.Scan(
-			&p.ID,
-			&p.Title,
-			&p.Description,
-			&p.ImagePath,
-			&p.AuthorEmail,
-			&p.Approved,
-			&p.CreatedAt,
-			&p.DeletedAt,
-		); err != nil {
-			return nil, err
-		}
-		projects = append(projects, p)
+	if err := insertRevision(ctx, tx, id); err != nil {
+		return err
 	}
 
-	return projects, nil
+	return tx.Commit(ctx)
+}
+
+// ListArchived returns a page of archived projects. Newest means most
+// recently archived.
+func (r *ProjectRepository) ListArchived(ctx context.Context, req PageRequest) (ProjectPage, error) {
+	return r.listPage(ctx, `status = $1`, []any{string(models.StatusArchived)}, "deleted_at", req)
 }
 
+// Approve publishes a pending or rejected project. A non-nil publishAt
+// keeps it out of the public listings until that time.
 func (r *ProjectRepository) Approve(
 	ctx context.Context,
 	projectID int,
+	publishAt *time.Time,
 ) error {
-	_, err := r.DB.Exec(ctx, `
+	cmd, err := r.DB.Exec(ctx, `
 		UPDATE projects
-		SET approved = true
+		SET status = 'approved',
+		    publish_at = $2,
+		    rejection_reason = '',
+		    edited_at = NULL,
+		    edited_fields = '{}',
+		    approved_revision_id = (
+		        SELECT MAX(id) FROM project_revisions WHERE project_id = $1
+		    )
 		WHERE id = $1
-	`, projectID)
+		  AND status IN ('pending', 'rejected')
+	`, projectID, publishAt)
+	if err != nil {
+		return err
+	}
 
-	return err
+	if cmd.RowsAffected() == 0 {
+		return errors.New("project not found or not awaiting review")
+	}
+
+	return nil
+}
+
+// Reject sends a pending project back to its author with the moderator's reason.
+func (r *ProjectRepository) Reject(ctx context.Context, projectID int, reason string) error {
+	cmd, err := r.DB.Exec(ctx, `
+		UPDATE projects
+		SET status = 'rejected',
+		    rejection_reason = $2
+		WHERE id = $1
+		  AND status = 'pending'
+	`, projectID, reason)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return errors.New("project not found or not pending")
+	}
+
+	return nil
 }
 
 func (r *ProjectRepository) Unapprove(ctx context.Context, projectID int) error {
 	query := `
 		UPDATE projects
-		SET approved = false
+		SET status = 'pending',
+		    publish_at = NULL
 		WHERE id = $1
-		AND deleted_at IS NULL
+		AND status = 'approved'
 	`
 	cmd, err := r.DB.Exec(ctx, query, projectID)
 	if err != nil {
@@ -161,7 +178,7 @@ func (r *ProjectRepository) Unapprove(ctx context.Context, projectID int) error
 	}
 
 	if cmd.RowsAffected() == 0 {
-		return errors.New("project not found or deleted")
+		return errors.New("project not found or not approved")
 	}
 
 	return nil
@@ -170,9 +187,11 @@ func (r *ProjectRepository) Unapprove(ctx context.Context, projectID int) error
 func (r *ProjectRepository) Archive(ctx context.Context, projectID int) error {
 	query := `
 		UPDATE projects
-		SET deleted_at = NOW()
+		SET archived_from = status,
+		    status = 'archived',
+		    deleted_at = NOW()
 		WHERE id = $1
-		AND deleted_at IS NULL
+		AND status <> 'archived'
 	`
 	cmd, err := r.DB.Exec(ctx, query, projectID)
 	if err != nil {
@@ -195,18 +214,20 @@ func (r *ProjectRepository) GetApprovedByID(
 
 	err := r.DB.QueryRow(ctx, `
 		SELECT id, title, project_description, image_path,
-		       author_email, created_at
+		       author_email, status, created_at, publish_at
 		FROM projects
 		WHERE id = $1
-		  AND approved = true
-		  AND deleted_at IS NULL
+		  AND status = 'approved'
+		  AND (publish_at IS NULL OR publish_at <= NOW())
 	`, id).Scan(
 		&p.ID,
 		&p.Title,
 		&p.Description,
 		&p.ImagePath,
 		&p.AuthorEmail,
+		&p.Status,
 		&p.CreatedAt,
+		&p.PublishAt,
 	)
 
 	if err != nil {
@@ -216,32 +237,361 @@ func (r *ProjectRepository) GetApprovedByID(
 		return nil, err
 	}
 
-	return &p, nil
+	if p.Images, err = r.listImages(ctx, p.ID); err != nil {
+		return nil, err
+	}
+
+	return r.withTags(ctx, p)
 }
 
-func (r *ProjectRepository) Restore(ctx context.Context, id int) error {
-	cmd, err := r.DB.Exec(ctx, `
+// Restore brings an archived project back to the status it had when it
+// was archived, so a draft stays a draft, and returns that status.
+// Projects archived without a recorded status go to the moderation queue.
+func (r *ProjectRepository) Restore(ctx context.Context, id int) (models.ProjectStatus, error) {
+	var status models.ProjectStatus
+	err := r.DB.QueryRow(ctx, `
 		UPDATE projects
-		SET deleted_at = NULL,
-		    approved = false
+		SET status = COALESCE(archived_from, 'pending'),
+		    archived_from = NULL,
+		    deleted_at = NULL
 		WHERE id = $1
-		  AND deleted_at IS NOT NULL
+		  AND status = 'archived'
+		RETURNING status
+	`, id).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", errors.New("project is not archived")
+	}
+	return status, err
+}
+
+// DeleteForever removes a project with its gallery, revisions and tags.
+// It returns the storage keys of the images the project referenced, whose
+// files the caller should delete.
+func (r *ProjectRepository) DeleteForever(ctx context.Context, id int) ([]string, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Images removed from the gallery keep their files for the revisions
+	// that show them, so collect those too.
+	rows, err := tx.Query(ctx, `
+		SELECT path FROM project_images WHERE project_id = $1
+		UNION
+		SELECT ri.path
+		FROM project_revision_images ri
+		JOIN project_revisions r ON r.id = ri.revision_id
+		WHERE r.project_id = $1
 	`, id)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM projects
+		WHERE id = $1
+	`, id); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// GetByID returns a project in any moderation state, or nil if it does not exist.
+func (r *ProjectRepository) GetByID(ctx context.Context, id int) (*models.Project, error) {
+	var p models.Project
+	var deletedAt *time.Time
+
+	err := r.DB.QueryRow(ctx, `
+		SELECT id, title, project_description, image_path,
+		       author_email, status, rejection_reason, created_at, deleted_at,
+		       edited_at, edited_fields, approved_revision_id, publish_at
+		FROM projects
+		WHERE id = $1
+	`, id).Scan(
+		&p.ID,
+		&p.Title,
+		&p.Description,
+		&p.ImagePath,
+		&p.AuthorEmail,
+		&p.Status,
+		&p.RejectionReason,
+		&p.CreatedAt,
+		&deletedAt,
+		&p.EditedAt,
+		&p.EditedFields,
+		&p.ApprovedRevisionID,
+		&p.PublishAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if deletedAt != nil {
+		p.DeletedAt = *deletedAt
+	}
+
+	if p.Images, err = r.listImages(ctx, p.ID); err != nil {
+		return nil, err
+	}
+
+	return r.withTags(ctx, p)
+}
+
+// UpdateByAuthor saves an author's edit, including its gallery and tags,
+// and moves the project to status: StatusPending sends it back to the
+// moderation queue, StatusDraft keeps working on a draft. changedFields is
+// merged into the fields already edited since the last approval. Archived
+// projects and projects owned by someone else are not touched, and only
+// drafts can stay drafts.
+func (r *ProjectRepository) UpdateByAuthor(
+	ctx context.Context,
+	id int,
+	authorEmail string,
+	title string,
+	description string,
+	images []models.ProjectImage,
+	changedFields []string,
+	status models.ProjectStatus,
+	tags []string,
+) error {
+	if status != models.StatusDraft && status != models.StatusPending {
+		return errors.New("edited projects must be drafts or pending")
+	}
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	cmd, err := tx.Exec(ctx, `
+		UPDATE projects
+		SET title = $3,
+		    project_description = $4,
+		    status = $6,
+		    publish_at = NULL,
+		    rejection_reason = '',
+		    edited_at = NOW(),
+		    edited_fields = ARRAY(
+		        SELECT DISTINCT f FROM unnest(edited_fields || $5::text[]) AS f ORDER BY f
+		    )
+		WHERE id = $1
+		  AND author_email = $2
+		  AND status <> 'archived'
+		  AND ($6 = 'pending' OR status = 'draft')
+	`,
+		id,
+		authorEmail,
+		title,
+		description,
+		changedFields,
+		status,
+	)
 	if err != nil {
 		return err
 	}
 
 	if cmd.RowsAffected() == 0 {
-		return errors.New("project is not archived")
+		return errors.New("project not found, archived or not owned by author")
 	}
 
-	return nil
+	if err := setProjectImages(ctx, tx, id, images); err != nil {
+		return err
+	}
+
+	if err := setProjectTags(ctx, tx, id, tags); err != nil {
+		return err
+	}
+
+	if err := insertRevision(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
-func (r *ProjectRepository) DeleteForever(ctx context.Context, id int) error {
-	_, err := r.DB.Exec(ctx, `
-		DELETE FROM projects
+// Submit sends an author's draft to the moderation queue unchanged.
+func (r *ProjectRepository) Submit(ctx context.Context, id int, authorEmail string) error {
+	cmd, err := r.DB.Exec(ctx, `
+		UPDATE projects
+		SET status = 'pending'
 		WHERE id = $1
-	`, id)
+		  AND author_email = $2
+		  AND status = 'draft'
+	`, id, authorEmail)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return errors.New("draft not found or not owned by author")
+	}
+
+	return nil
+}
+
+// insertRevision snapshots the project's current content, tags and
+// gallery as a new revision, so it must run after they are saved.
+// Revisions record a missing cover image as an empty path.
+func insertRevision(ctx context.Context, tx pgx.Tx, projectID int) error {
+	var revisionID int
+	err := tx.QueryRow(ctx, `
+		INSERT INTO project_revisions (project_id, title, project_description, image_path, author_email, tags)
+		SELECT p.id, p.title, p.project_description, COALESCE(p.image_path, ''), p.author_email,
+		       ARRAY(
+		           SELECT t.name
+		           FROM project_tags pt
+		           JOIN tags t ON t.id = pt.tag_id
+		           WHERE pt.project_id = p.id
+		           ORDER BY lower(t.name)
+		       )
+		FROM projects p
+		WHERE p.id = $1
+		RETURNING id
+	`, projectID).Scan(&revisionID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO project_revision_images (revision_id, position, path, alt_text, caption, has_variants)
+		SELECT $1, ROW_NUMBER() OVER (ORDER BY position, id), path, alt_text, caption, has_variants
+		FROM project_images
+		WHERE project_id = $2
+	`, revisionID, projectID)
 	return err
 }
+
+// ListRevisions returns a project's revisions, oldest first.
+func (r *ProjectRepository) ListRevisions(ctx context.Context, projectID int) ([]models.ProjectRevision, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT id, project_id, title, project_description, image_path, tags, author_email, created_at
+		FROM project_revisions
+		WHERE project_id = $1
+		ORDER BY id ASC
+	`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []models.ProjectRevision
+	for rows.Next() {
+		var rev models.ProjectRevision
+		if err := rows.Scan(
+			&rev.ID,
+			&rev.ProjectID,
+			&rev.Title,
+			&rev.Description,
+			&rev.ImagePath,
+			&rev.Tags,
+			&rev.AuthorEmail,
+			&rev.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, rows.Err()
+}
+
+// GetRevision returns one revision of a project with its gallery, or nil
+// if it does not exist.
+func (r *ProjectRepository) GetRevision(ctx context.Context, projectID, revisionID int) (*models.ProjectRevision, error) {
+	var rev models.ProjectRevision
+
+	err := r.DB.QueryRow(ctx, `
+		SELECT id, project_id, title, project_description, image_path, tags, author_email, created_at
+		FROM project_revisions
+		WHERE project_id = $1
+		  AND id = $2
+	`, projectID, revisionID).Scan(
+		&rev.ID,
+		&rev.ProjectID,
+		&rev.Title,
+		&rev.Description,
+		&rev.ImagePath,
+		&rev.Tags,
+		&rev.AuthorEmail,
+		&rev.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if rev.Images, err = r.listRevisionImages(ctx, rev.ID); err != nil {
+		return nil, err
+	}
+
+	return &rev, nil
+}
+
+// ListByAuthor returns every project submitted by authorEmail in any
+// moderation state, including archived ones, newest first.
+func (r *ProjectRepository) ListByAuthor(ctx context.Context, authorEmail string) ([]models.Project, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT id, title, project_description, image_path, author_email, status, rejection_reason,
+		       created_at, deleted_at, edited_at, edited_fields, publish_at
+		FROM projects
+		WHERE author_email = $1
+		ORDER BY created_at DESC
+	`, authorEmail)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []models.Project
+	for rows.Next() {
+		var p models.Project
+		var deletedAt *time.Time
+		if err := rows.Scan(
+			&p.ID,
+			&p.Title,
+			&p.Description,
+			&p.ImagePath,
+			&p.AuthorEmail,
+			&p.Status,
+			&p.RejectionReason,
+			&p.CreatedAt,
+			&deletedAt,
+			&p.EditedAt,
+			&p.EditedFields,
+			&p.PublishAt,
+		); err != nil {
+			return nil, err
+		}
+		if deletedAt != nil {
+			p.DeletedAt = *deletedAt
+		}
+		projects = append(projects, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.attachTags(ctx, projects); err != nil {
+		return nil, err
+	}
+
+	return projects, nil
+}
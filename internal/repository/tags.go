@@ -0,0 +1,230 @@
+// Repository for tags and their assignment to projects
+
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/janphilippgutt/casproject/internal/models"
+)
+
+type TagRepository struct {
+	DB *pgxpool.Pool
+}
+
+// publicProjectCount counts the public projects carrying tag t.
+const publicProjectCount = `(
+	SELECT COUNT(*)
+	FROM project_tags pt
+	JOIN projects p ON p.id = pt.project_id
+	WHERE pt.tag_id = t.id
+	  AND p.status = 'approved'
+	  AND (p.publish_at IS NULL OR p.publish_at <= NOW())
+)`
+
+// ListCurated returns the curated tags, alphabetically.
+func (r *TagRepository) ListCurated(ctx context.Context) ([]models.Tag, error) {
+	return r.list(ctx, `
+		SELECT id, slug, name, curated, description, 0
+		FROM tags
+		WHERE curated
+		ORDER BY lower(name)
+	`)
+}
+
+// ListAll returns every tag with its number of public projects, curated
+// tags first.
+func (r *TagRepository) ListAll(ctx context.Context) ([]models.Tag, error) {
+	return r.list(ctx, `
+		SELECT t.id, t.slug, t.name, t.curated, t.description, `+publicProjectCount+`
+		FROM tags t
+		ORDER BY t.curated DESC, lower(t.name)
+	`)
+}
+
+// ListPublic returns the curated tags plus the free tags used by at least
+// one public project, most used first.
+func (r *TagRepository) ListPublic(ctx context.Context) ([]models.Tag, error) {
+	return r.list(ctx, `
+		SELECT id, slug, name, curated, description, project_count
+		FROM (
+			SELECT t.id, t.slug, t.name, t.curated, t.description, `+publicProjectCount+` AS project_count
+			FROM tags t
+		) counted
+		WHERE curated OR project_count > 0
+		ORDER BY curated DESC, project_count DESC, lower(name)
+	`)
+}
+
+func (r *TagRepository) list(ctx context.Context, query string) ([]models.Tag, error) {
+	rows, err := r.DB.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []models.Tag
+	for rows.Next() {
+		var t models.Tag
+		if err := rows.Scan(&t.ID, &t.Slug, &t.Name, &t.Curated, &t.Description, &t.ProjectCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
+
+// GetBySlug returns the tag with the given slug, or nil if there is none.
+func (r *TagRepository) GetBySlug(ctx context.Context, slug string) (*models.Tag, error) {
+	var t models.Tag
+
+	err := r.DB.QueryRow(ctx, `
+		SELECT id, slug, name, curated, description
+		FROM tags
+		WHERE slug = $1
+	`, slug).Scan(&t.ID, &t.Slug, &t.Name, &t.Curated, &t.Description)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// SaveCurated adds name to the curated vocabulary. A free tag with the same
+// slug is promoted and takes over the new name and description.
+func (r *TagRepository) SaveCurated(ctx context.Context, name, description string) error {
+	slug := models.TagSlug(name)
+	if slug == "" {
+		return errors.New("tag name needs at least one letter or digit")
+	}
+
+	_, err := r.DB.Exec(ctx, `
+		INSERT INTO tags (slug, name, curated, description)
+		VALUES ($1, $2, TRUE, $3)
+		ON CONFLICT (slug) DO UPDATE
+		SET name = EXCLUDED.name,
+		    curated = TRUE,
+		    description = EXCLUDED.description
+	`, slug, name, description)
+	return err
+}
+
+// SetCurated promotes a free tag to the curated vocabulary or demotes a
+// curated one to a free tag.
+func (r *TagRepository) SetCurated(ctx context.Context, id int, curated bool) error {
+	cmd, err := r.DB.Exec(ctx, `
+		UPDATE tags
+		SET curated = $2
+		WHERE id = $1
+	`, id, curated)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return errors.New("tag not found")
+	}
+
+	return nil
+}
+
+// Delete removes a tag from every project and from the vocabulary.
+func (r *TagRepository) Delete(ctx context.Context, id int) error {
+	_, err := r.DB.Exec(ctx, `
+		DELETE FROM tags
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+// setProjectTags replaces the tags of a project with names, creating free
+// tags for names that don't exist yet. Names are matched by slug, so
+// "Open Source" picks up an existing "open-source" tag.
+func setProjectTags(ctx context.Context, tx pgx.Tx, projectID int, names []string) error {
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM project_tags
+		WHERE project_id = $1
+	`, projectID); err != nil {
+		return err
+	}
+
+	for _, name := range models.CleanTagNames(names) {
+		var tagID int
+		// The no-op update makes RETURNING yield the existing row's id.
+		err := tx.QueryRow(ctx, `
+			INSERT INTO tags (slug, name)
+			VALUES ($1, $2)
+			ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+			RETURNING id
+		`, models.TagSlug(name), name).Scan(&tagID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO project_tags (project_id, tag_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, projectID, tagID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// attachTags loads the tags of projects into their Tags fields with a
+// single query.
+func (r *ProjectRepository) attachTags(ctx context.Context, projects []models.Project) error {
+	if len(projects) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(projects))
+	index := make(map[int]int, len(projects))
+	for i, p := range projects {
+		ids[i] = p.ID
+		index[p.ID] = i
+	}
+
+	rows, err := r.DB.Query(ctx, `
+		SELECT pt.project_id, t.id, t.slug, t.name, t.curated
+		FROM project_tags pt
+		JOIN tags t ON t.id = pt.tag_id
+		WHERE pt.project_id = ANY($1)
+		ORDER BY t.curated DESC, lower(t.name)
+	`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var projectID int
+		var t models.Tag
+		if err := rows.Scan(&projectID, &t.ID, &t.Slug, &t.Name, &t.Curated); err != nil {
+			return err
+		}
+		i := index[projectID]
+		projects[i].Tags = append(projects[i].Tags, t)
+	}
+
+	return rows.Err()
+}
+
+// withTags returns p with its tags loaded.
+func (r *ProjectRepository) withTags(ctx context.Context, p models.Project) (*models.Project, error) {
+	projects := []models.Project{p}
+	if err := r.attachTags(ctx, projects); err != nil {
+		return nil, err
+	}
+	return &projects[0], nil
+}
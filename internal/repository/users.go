@@ -0,0 +1,179 @@
+// Repository for user account concerns
+
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/janphilippgutt/casproject/internal/models"
+)
+
+type UserRepository struct {
+	DB *pgxpool.Pool
+}
+
+// List returns all users, or those whose email contains query
+// (case-insensitive), ordered by email.
+func (r *UserRepository) List(ctx context.Context, query string) ([]models.User, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT id, email, role, created_at, deactivated_at, sessions_revoked_at
+		FROM users
+		WHERE $1 = '' OR email ILIKE '%' || $1 || '%'
+		ORDER BY email ASC
+	`, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(
+			&u.ID,
+			&u.Email,
+			&u.Role,
+			&u.CreatedAt,
+			&u.DeactivatedAt,
+			&u.SessionsRevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id int) (*models.User, error) {
+	var u models.User
+
+	err := r.DB.QueryRow(ctx, `
+		SELECT id, email, role, created_at, deactivated_at, sessions_revoked_at
+		FROM users
+		WHERE id = $1
+	`, id).Scan(
+		&u.ID,
+		&u.Email,
+		&u.Role,
+		&u.CreatedAt,
+		&u.DeactivatedAt,
+		&u.SessionsRevokedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// GetByEmail returns nil (and no error) if no user has that email.
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var u models.User
+
+	err := r.DB.QueryRow(ctx, `
+		SELECT id, email, role, created_at, deactivated_at, sessions_revoked_at
+		FROM users
+		WHERE email = $1
+	`, email).Scan(
+		&u.ID,
+		&u.Email,
+		&u.Role,
+		&u.CreatedAt,
+		&u.DeactivatedAt,
+		&u.SessionsRevokedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+func (r *UserRepository) SetRole(ctx context.Context, id int, role string) error {
+	if !models.IsValidRole(role) {
+		return errors.New("invalid role")
+	}
+
+	cmd, err := r.DB.Exec(ctx, `
+		UPDATE users
+		SET role = $2
+		WHERE id = $1
+	`, id, role)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+func (r *UserRepository) Deactivate(ctx context.Context, id int) error {
+	cmd, err := r.DB.Exec(ctx, `
+		UPDATE users
+		SET deactivated_at = NOW(),
+		    sessions_revoked_at = NOW()
+		WHERE id = $1
+		  AND deactivated_at IS NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return errors.New("user not found or already deactivated")
+	}
+
+	return nil
+}
+
+// RevokeSessions ends all of the user's current sessions; they are
+// destroyed on their next request (see middleware.RevalidateSession).
+func (r *UserRepository) RevokeSessions(ctx context.Context, id int) error {
+	cmd, err := r.DB.Exec(ctx, `
+		UPDATE users
+		SET sessions_revoked_at = NOW()
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+func (r *UserRepository) Reactivate(ctx context.Context, id int) error {
+	cmd, err := r.DB.Exec(ctx, `
+		UPDATE users
+		SET deactivated_at = NULL
+		WHERE id = $1
+		  AND deactivated_at IS NOT NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return errors.New("user not found or not deactivated")
+	}
+
+	return nil
+}
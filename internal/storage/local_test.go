@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLocalStore(t *testing.T) {
+	dir := t.TempDir()
+	s := &LocalStore{Dir: dir}
+
+	testStore(t, s, "projects/")
+
+	t.Run("URL", func(t *testing.T) {
+		u, err := s.URL(context.Background(), "projects/a.txt", time.Minute)
+		if err != nil || u != "" {
+			t.Errorf("URL = %q, %v; want \"\", nil", u, err)
+		}
+	})
+
+	t.Run("no file outside Dir", func(t *testing.T) {
+		for _, key := range invalidKeys {
+			s.Put(context.Background(), key, nil, 0, "")
+		}
+		entries, err := os.ReadDir(filepath.Dir(dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if e.Name() != filepath.Base(dir) {
+				t.Errorf("unexpected file %s next to Dir", e.Name())
+			}
+		}
+	})
+
+	t.Run("List of an empty store", func(t *testing.T) {
+		empty := &LocalStore{Dir: filepath.Join(dir, "missing")}
+		err := empty.List(context.Background(), "", func(obj Object) error {
+			t.Errorf("List called fn for %s", obj.Key)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("List: %v", err)
+		}
+	})
+}
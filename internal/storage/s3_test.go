@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestS3Store runs against a real bucket, configured like the app with
+// S3_ENDPOINT, S3_BUCKET, S3_REGION, S3_ACCESS_KEY_ID, S3_SECRET_ACCESS_KEY
+// and S3_USE_SSL, e.g. the MinIO service of docker-compose.yml. It is
+// skipped unless S3_ENDPOINT is set.
+func TestS3Store(t *testing.T) {
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_ENDPOINT not set")
+	}
+	region := os.Getenv("S3_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s, err := NewS3Store(ctx, S3Config{
+		Endpoint:        endpoint,
+		Region:          region,
+		Bucket:          os.Getenv("S3_BUCKET"),
+		AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		UseSSL:          os.Getenv("S3_USE_SSL") != "false",
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+
+	// A prefix of its own, so the test neither sees nor touches real uploads.
+	prefix := "storage-test-" + strconv.FormatInt(time.Now().UnixNano(), 36) + "/"
+	testStore(t, s, prefix)
+
+	t.Run("URL", func(t *testing.T) {
+		key := prefix + "url.txt"
+		put(t, s, key, "presigned")
+		defer s.Delete(context.Background(), key)
+
+		u, err := s.URL(context.Background(), key, time.Minute)
+		if err != nil {
+			t.Fatalf("URL: %v", err)
+		}
+		resp, err := http.Get(u)
+		if err != nil {
+			t.Fatalf("GET %s: %v", u, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || string(body) != "presigned" {
+			t.Errorf("GET presigned URL = %d %q, want 200 %q", resp.StatusCode, body, "presigned")
+		}
+	})
+}
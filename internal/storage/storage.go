@@ -0,0 +1,48 @@
+// Package storage keeps uploaded files in a pluggable blob store.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by Get for keys that hold no object.
+var ErrNotFound = errors.New("storage: object not found")
+
+// Store keeps uploaded files under keys such as "projects/<uuid>.png":
+// slash-separated, relative and without "." or ".." segments.
+type Store interface {
+	// Put stores size bytes read from r under key, replacing any object
+	// already there.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object under key. The caller closes it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object under key. Missing objects are not an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns an address browsers can load the object from directly
+	// during the next ttl, or "" if the store has none and the app serves
+	// the object itself with Get.
+	URL(ctx context.Context, key string, ttl time.Duration) (string, error)
+	// List calls fn for every object whose key starts with prefix, in no
+	// particular order, and stops at the first error fn returns.
+	List(ctx context.Context, prefix string, fn func(Object) error) error
+}
+
+// Object describes a stored object.
+type Object struct {
+	Key     string
+	ModTime time.Time
+}
+
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return fmt.Errorf("storage: invalid key %q", key)
+	}
+	return nil
+}
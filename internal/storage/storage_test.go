@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"projects/abc.png", true},
+		{"projects/abc_card.webp", true},
+		{"a", true},
+		{"a/b/c", true},
+		{"", false},
+		{"/projects/abc.png", false},
+		{"..", false},
+		{"../abc.png", false},
+		{"projects/../../abc.png", false},
+		{"projects/../abc.png", false},
+		{"./abc.png", false},
+		{"projects//abc.png", false},
+		{"projects/", false},
+	}
+
+	for _, tt := range tests {
+		err := checkKey(tt.key)
+		if tt.valid && err != nil {
+			t.Errorf("checkKey(%q) = %v, want nil", tt.key, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("checkKey(%q) = nil, want an error", tt.key)
+		}
+	}
+}
+
+// invalidKeys must be rejected by every method that takes a key.
+var invalidKeys = []string{"", "/abs.png", "..", "../up.png", "a/../../up.png", "a//b.png"}
+
+// testStore runs the behaviour every Store shares, with all keys under
+// prefix, and leaves nothing behind.
+func testStore(t *testing.T, s Store, prefix string) {
+	t.Helper()
+	ctx := context.Background()
+
+	t.Run("invalid keys", func(t *testing.T) {
+		for _, key := range invalidKeys {
+			if err := s.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"); err == nil {
+				t.Errorf("Put(%q) succeeded", key)
+			}
+			if _, err := s.Get(ctx, key); err == nil {
+				t.Errorf("Get(%q) succeeded", key)
+			}
+			if err := s.Delete(ctx, key); err == nil {
+				t.Errorf("Delete(%q) succeeded", key)
+			}
+			if _, err := s.URL(ctx, key, time.Minute); err == nil {
+				t.Errorf("URL(%q) succeeded", key)
+			}
+		}
+	})
+
+	keyA := prefix + "a.txt"
+	keyB := prefix + "sub/b.txt"
+
+	t.Run("round trip", func(t *testing.T) {
+		put(t, s, keyA, "first")
+		put(t, s, keyA, "second") // replaces
+		put(t, s, keyB, "other")
+		t.Cleanup(func() {
+			s.Delete(ctx, keyA)
+			s.Delete(ctx, keyB)
+		})
+
+		if got := get(t, s, keyA); got != "second" {
+			t.Errorf("Get(%q) = %q, want %q", keyA, got, "second")
+		}
+		if got := get(t, s, keyB); got != "other" {
+			t.Errorf("Get(%q) = %q, want %q", keyB, got, "other")
+		}
+
+		var keys []string
+		err := s.List(ctx, prefix, func(obj Object) error {
+			if time.Since(obj.ModTime) > time.Hour || time.Until(obj.ModTime) > time.Hour {
+				t.Errorf("List: %s has ModTime %v", obj.Key, obj.ModTime)
+			}
+			keys = append(keys, obj.Key)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		sort.Strings(keys)
+		if want := []string{keyA, keyB}; strings.Join(keys, ",") != strings.Join(want, ",") {
+			t.Errorf("List(%q) = %q, want %q", prefix, keys, want)
+		}
+
+		errStop := errors.New("stop")
+		calls := 0
+		err = s.List(ctx, prefix, func(Object) error {
+			calls++
+			return errStop
+		})
+		if !errors.Is(err, errStop) || calls != 1 {
+			t.Errorf("List stopping early: err = %v after %d calls, want %v after 1", err, calls, errStop)
+		}
+
+		if err := s.Delete(ctx, keyA); err != nil {
+			t.Fatalf("Delete(%q): %v", keyA, err)
+		}
+		if _, err := s.Get(ctx, keyA); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get after Delete: err = %v, want ErrNotFound", err)
+		}
+		if err := s.Delete(ctx, keyA); err != nil {
+			t.Errorf("Delete of a missing key: %v", err)
+		}
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		if _, err := s.Get(ctx, prefix+"missing.txt"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get of a missing key: err = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func put(t *testing.T, s Store, key, content string) {
+	t.Helper()
+	if err := s.Put(context.Background(), key, strings.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatalf("Put(%q): %v", key, err)
+	}
+}
+
+func get(t *testing.T, s Store, key string) string {
+	t.Helper()
+	rc, err := s.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", key, err)
+	}
+	defer rc.Close()
+
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read %q: %v", key, err)
+	}
+	return string(b)
+}
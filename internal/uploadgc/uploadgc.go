@@ -0,0 +1,123 @@
+// Package uploadgc deletes uploaded images no project refers to: files of
+// uploads whose project was never saved, of projects deleted forever, of
+// removed gallery images only older revisions showed, or whose removal
+// failed.
+package uploadgc
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/janphilippgutt/casproject/internal/imaging"
+	"github.com/janphilippgutt/casproject/internal/repository"
+	"github.com/janphilippgutt/casproject/internal/storage"
+)
+
+// Prefix is where project images are stored.
+const Prefix = "projects/"
+
+// Collector reconciles the stored project images with the database.
+type Collector struct {
+	Store storage.Store
+	Repo  *repository.ProjectRepository
+	// Grace is how old a file must be before it can be collected. Uploads
+	// are stored before their project is saved, so younger files may
+	// still be about to be referenced.
+	Grace time.Duration
+}
+
+// Result summarizes a run.
+type Result struct {
+	Scanned int      // files under Prefix
+	Orphans []string // keys of files no project refers to, older than Grace
+	Failed  int      // orphans that could not be deleted
+}
+
+// Run finds orphaned files and, unless dryRun is set, deletes them.
+// Variants belong to their original and are kept as long as it is
+// referenced.
+func (c *Collector) Run(ctx context.Context, dryRun bool) (Result, error) {
+	var res Result
+	cutoff := time.Now().Add(-c.Grace)
+
+	var candidates []string
+	err := c.Store.List(ctx, Prefix, func(obj storage.Object) error {
+		res.Scanned++
+		if obj.ModTime.Before(cutoff) {
+			candidates = append(candidates, obj.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return res, err
+	}
+
+	// Load the references after listing, so a file saved and referenced
+	// while the listing ran is never taken for an orphan.
+	referenced, err := c.Repo.ImageKeys(ctx)
+	if err != nil {
+		return res, err
+	}
+
+	for _, key := range candidates {
+		if referenced[key] || referenced[imaging.OriginalKey(key)] {
+			continue
+		}
+		res.Orphans = append(res.Orphans, key)
+		if dryRun {
+			continue
+		}
+
+		if err := c.Store.Delete(ctx, key); err != nil {
+			res.Failed++
+			slog.Warn(
+				"remove orphaned upload failed",
+				"event.category", "file",
+				"event.type", "deletion",
+				"key", key,
+				"error", err,
+			)
+			continue
+		}
+		slog.Info(
+			"orphaned upload removed",
+			"event.category", "file",
+			"event.type", "deletion",
+			"key", key,
+		)
+	}
+
+	return res, nil
+}
+
+// Start periodically deletes orphaned files in the background.
+func (c *Collector) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			res, err := c.Run(ctx, false)
+			cancel()
+			if err != nil {
+				slog.Error(
+					"upload garbage collection failed",
+					"event.category", "file",
+					"event.type", "cleanup",
+					"error", err,
+				)
+				continue
+			}
+			slog.Info(
+				"upload garbage collection finished",
+				"event.category", "file",
+				"event.type", "cleanup",
+				"files.scanned", res.Scanned,
+				"files.orphaned", len(res.Orphans),
+				"files.failed", res.Failed,
+			)
+		}
+	}()
+}
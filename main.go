@@ -1,40 +1,84 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"html/template"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/alexedwards/scs/pgxstore"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
 
 	"github.com/janphilippgutt/casproject/handlers"
 	"github.com/janphilippgutt/casproject/internal/auth"
+	"github.com/janphilippgutt/casproject/internal/config"
 	"github.com/janphilippgutt/casproject/internal/db"
+	"github.com/janphilippgutt/casproject/internal/imaging"
+	"github.com/janphilippgutt/casproject/internal/mail"
+	"github.com/janphilippgutt/casproject/internal/markdown"
 	"github.com/janphilippgutt/casproject/internal/repository"
+	"github.com/janphilippgutt/casproject/internal/storage"
+	"github.com/janphilippgutt/casproject/internal/uploadgc"
 	"github.com/janphilippgutt/casproject/middleware"
 )
 
-func ensureDirs() error {
-	dirs := []string{
-		"./uploads",
-		"./uploads/projects",
+// templateFuncs returns the functions available in every page template;
+// url turns the storage key of an uploaded image into the URL pages use,
+// and times are shown in loc.
+func templateFuncs(url func(key string) string, loc *time.Location) template.FuncMap {
+	return template.FuncMap{
+		// markdown renders a project description as sanitized HTML.
+		"markdown": markdown.Render,
+		// plaintext strips the Markdown from a description, for excerpts.
+		"plaintext": markdown.PlainText,
+		// upload turns the storage key of an uploaded image into its URL.
+		"upload": url,
+		// variant is the URL of a resized variant ("thumb", "card", "full")
+		// of an uploaded image; srcset lists all of them with their widths.
+		"variant": func(key, name string) string {
+			return url(imaging.VariantKey(key, name))
+		},
+		"srcset": func(key string) string {
+			return imaging.Srcset(key, url)
+		},
+		// datetime formats a time in the app's zone, naming the zone;
+		// timezone is that zone, e.g. to label inputs.
+		"datetime": func(t time.Time) string {
+			return t.In(loc).Format(handlers.DateTimeLayout)
+		},
+		"timezone": loc.String,
 	}
+}
 
-	for _, dir := range dirs {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return err
-		}
+// openStore returns the store uploaded images live in: local disk unless
+// STORAGE_BACKEND=s3.
+func openStore(cfg config.StorageConfig) (storage.Store, error) {
+	if cfg.Backend != "s3" {
+		return &storage.LocalStore{Dir: cfg.LocalDir}, nil
 	}
-	return nil
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return storage.NewS3Store(ctx, storage.S3Config{
+		Endpoint:        cfg.S3Endpoint,
+		Region:          cfg.S3Region,
+		Bucket:          cfg.S3Bucket,
+		AccessKeyID:     cfg.S3AccessKeyID,
+		SecretAccessKey: cfg.S3SecretAccessKey,
+		UseSSL:          cfg.S3UseSSL,
+	})
 }
 
-func mustParse(name string, files ...string) *template.Template {
-	t := template.Must(template.ParseFiles(files...))
+func mustParse(funcs template.FuncMap, name string, files ...string) *template.Template {
+	t := template.Must(template.New(filepath.Base(files[0])).Funcs(funcs).ParseFiles(files...))
 	log.Printf("parsed templates for %s: %q\n", name, t.DefinedTemplates())
 	return t
 }
@@ -45,6 +89,19 @@ func main() {
 		log.Println("no .env file found")
 	}
 
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("invalid configuration:", err)
+	}
+
+	// "gc-uploads" deletes orphaned uploads once and exits instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "gc-uploads" {
+		if err := gcUploads(cfg, os.Args[2:]); err != nil {
+			log.Fatal("upload garbage collection failed: ", err)
+		}
+		return
+	}
+
 	logFile, err := os.OpenFile(
 		"logs/app.log",
 		os.O_CREATE|os.O_WRONLY|os.O_APPEND,
@@ -76,31 +133,115 @@ func main() {
 
 	log.Println("database connected")
 
-	tokenStore := auth.NewTokenStore()
+	// Magic login tokens live in Postgres unless TOKEN_STORE=memory (dev only:
+	// tokens are lost on restart and not shared between instances).
+	var tokenStore auth.TokenStore
+	if cfg.TokenStore == "memory" {
+		tokenStore = auth.NewMemoryTokenStore()
+	} else {
+		tokenStore = auth.NewPostgresTokenStore(dbPool)
+	}
 
 	tokenStore.StartCleanup(1 * time.Minute)
 
+	var mailer mail.Mailer
+	switch cfg.Mail.Driver {
+	case "smtp":
+		mailer = &mail.SMTPMailer{
+			Host:     cfg.Mail.SMTPHost,
+			Port:     cfg.Mail.SMTPPort,
+			Username: cfg.Mail.SMTPUsername,
+			Password: cfg.Mail.SMTPPassword,
+			From:     cfg.Mail.From,
+			Timeout:  cfg.Mail.SMTPTimeout,
+		}
+	default:
+		mailer = &mail.OutboxMailer{Dir: cfg.Mail.OutboxDir, From: cfg.Mail.From}
+	}
+
+	emails, err := mail.ParseTemplates("templates/email")
+	if err != nil {
+		log.Fatal("failed to parse email templates:", err)
+	}
+
+	magicLinks := &auth.MagicLinks{
+		Tokens:  tokenStore,
+		Mailer:  mailer,
+		Emails:  emails,
+		BaseURL: cfg.BaseURL,
+	}
+
 	sessionManager := scs.New()
+	if cfg.SessionStore == "postgres" {
+		// Shared across instances and survives deploys; expired rows are purged periodically.
+		sessionManager.Store = pgxstore.NewWithCleanupInterval(dbPool, 5*time.Minute)
+	}
 	sessionManager.Lifetime = 24 * time.Hour
 	sessionManager.Cookie.HttpOnly = true
 	sessionManager.Cookie.SameSite = http.SameSiteLaxMode
 	sessionManager.Cookie.Secure = false // for local dev; set true in production
 
+	store, err := openStore(cfg.Storage)
+	if err != nil {
+		log.Fatal("object storage unavailable:", err)
+	}
+
+	// Images of projects that aren't public are only served through signed
+	// URLs, handed out on the pages of authors and reviewers.
+	urlSecret := []byte(cfg.Storage.URLSecret)
+	if len(urlSecret) == 0 {
+		urlSecret = make([]byte, 32)
+		if _, err := rand.Read(urlSecret); err != nil {
+			log.Fatal("failed to generate upload URL secret:", err)
+		}
+		log.Println("UPLOAD_URL_SECRET not set; signed image URLs stop working on restart")
+	}
+	signer := &auth.URLSigner{Secret: urlSecret, TTL: cfg.Storage.URLTTL}
+
+	funcs := templateFuncs(handlers.UploadPath, cfg.Timezone)
+	signedFuncs := templateFuncs(func(key string) string {
+		return signer.Sign(handlers.UploadPath(key))
+	}, cfg.Timezone)
+
 	// parse per-page template sets (base + specific page)
 	tpls := map[string]*template.Template{
-		"home":                    mustParse("home", "templates/base.html", "templates/home.html"),
-		"login":                   mustParse("login", "templates/base.html", "templates/login.html"),
-		"about":                   mustParse("about", "templates/base.html", "templates/about.html"),
-		"admin":                   mustParse("admin", "templates/base.html", "templates/admin.html"),
-		"new_project":             mustParse("new_project", "templates/base.html", "templates/project_new.html"),
-		"projects":                mustParse("projects", "templates/base.html", "templates/projects.html"),
-		"admin_projects":          mustParse("admin_projects", "templates/base.html", "templates/admin_projects.html"),
-		"project_detail":          mustParse("project_detail", "templates/base.html", "templates/project_detail.html"),
-		"admin_archived_projects": mustParse("admin_archived_projects", "templates/base.html", "templates/admin_archived_projects.html"),
+		"home":                    mustParse(funcs, "home", "templates/base.html", "templates/home.html"),
+		"login":                   mustParse(funcs, "login", "templates/base.html", "templates/login.html"),
+		"about":                   mustParse(funcs, "about", "templates/base.html", "templates/about.html"),
+		"admin":                   mustParse(funcs, "admin", "templates/base.html", "templates/admin.html"),
+		"new_project":             mustParse(funcs, "new_project", "templates/base.html", "templates/project_new.html"),
+		"projects":                mustParse(funcs, "projects", "templates/base.html", "templates/projects.html"),
+		"admin_projects":          mustParse(signedFuncs, "admin_projects", "templates/base.html", "templates/admin_projects.html"),
+		"project_detail":          mustParse(funcs, "project_detail", "templates/base.html", "templates/project_detail.html"),
+		"admin_archived_projects": mustParse(funcs, "admin_archived_projects", "templates/base.html", "templates/admin_archived_projects.html"),
+		"admin_users":             mustParse(funcs, "admin_users", "templates/base.html", "templates/admin_users.html"),
+		"project_edit":            mustParse(signedFuncs, "project_edit", "templates/base.html", "templates/project_edit.html"),
+		"admin_project_revisions": mustParse(signedFuncs, "admin_project_revisions", "templates/base.html", "templates/admin_project_revisions.html"),
+		"my_projects":             mustParse(funcs, "my_projects", "templates/base.html", "templates/my_projects.html"),
+		"register":                mustParse(funcs, "register", "templates/base.html", "templates/register.html"),
+		"tags":                    mustParse(funcs, "tags", "templates/base.html", "templates/tags.html"),
+		"admin_tags":              mustParse(funcs, "admin_tags", "templates/base.html", "templates/admin_tags.html"),
 	}
 
+	// Create repositories once
+	projectRepo := &repository.ProjectRepository{DB: dbPool}
+	userRepo := &repository.UserRepository{DB: dbPool}
+	tagRepo := &repository.TagRepository{DB: dbPool}
+
+	// Delete uploads no project refers to, e.g. after a failed save
+	uploadGC := &uploadgc.Collector{Store: store, Repo: projectRepo, Grace: cfg.Storage.GCGrace}
+	uploadGC.Start(cfg.Storage.GCInterval)
+
+	userCache := auth.NewUserCache(userRepo, cfg.UserCacheTTL)
+	userCache.StartCleanup(1 * time.Minute)
+
 	r := chi.NewRouter()
 
+	// Take the client IP from proxy headers only when told to
+	if cfg.TrustProxyHeaders {
+		r.Use(chimiddleware.RealIP)
+	}
+
 	// Wrap router with session manager middleware
 	r.Use(sessionManager.LoadAndSave)
 
@@ -110,49 +251,92 @@ func main() {
 	// Add request level logging with middleware
 	r.Use(middleware.RequestLogger(sessionManager))
 
+	// Re-check the logged-in user on every request so deactivations and role
+	// changes apply to live sessions
+	r.Use(middleware.RevalidateSession(sessionManager, userCache))
+
 	// Create middleware for authentication and authorization
 	authMW := middleware.AuthRequired(sessionManager)
-	requireAdmin := middleware.RequireAdmin(sessionManager)
-
-	// Create repository once
-	projectRepo := &repository.ProjectRepository{DB: dbPool}
-
-	// Create file server
-	fileServer := http.FileServer(http.Dir("./uploads"))
-	r.Handle("/uploads/*", http.StripPrefix("/uploads/", fileServer))
+	canApprove := middleware.RequirePermission(sessionManager, auth.PermProjectsApprove)
+	canArchive := middleware.RequirePermission(sessionManager, auth.PermProjectsArchive)
+	canDeleteForever := middleware.RequirePermission(sessionManager, auth.PermProjectsDeleteForever)
+	canManageUsers := middleware.RequirePermission(sessionManager, auth.PermUsersManage)
+	canManageTags := middleware.RequirePermission(sessionManager, auth.PermTagsManage)
+
+	// Reject state-changing requests without a valid per-session CSRF token.
+	// The middleware parses form bodies, so it also bounds their size: the
+	// project forms carry image uploads and get a larger limit, but only
+	// once the user is known to be signed in.
+	csrf := r.With(middleware.CSRF(sessionManager, middleware.MaxFormBytes))
+	uploadForms := r.With(authMW, middleware.CSRF(sessionManager, middleware.MaxUploadFormBytes))
+
+	// Uploaded images are served only while their project is public, or
+	// through a signed URL
+	r.Get("/uploads/*", handlers.ServeUpload(projectRepo, store, signer))
 
 	// use it for a route
-	r.With(authMW, requireAdmin).Get("/admin", handlers.Admin(tpls["admin"], sessionManager))
-	r.With(authMW).Get("/projects/new", handlers.NewProject(tpls["new_project"], projectRepo, sessionManager))
-	r.With(authMW).Post("/projects/new", handlers.NewProject(tpls["new_project"], projectRepo, sessionManager))
-	r.With(authMW, requireAdmin).Get("/admin/projects", handlers.ListUnapprovedProjects(tpls["admin_projects"], projectRepo, sessionManager))
-	r.With(authMW, requireAdmin).Post("/admin/projects/{id}/approve", handlers.ApproveProject(projectRepo))
-	r.With(authMW, requireAdmin).Post("/admin/projects/{id}/delete", handlers.ArchiveProject(projectRepo, sessionManager))
-	r.With(authMW, requireAdmin).Post("/admin/projects/{id}/unapprove", handlers.UnapproveProject(projectRepo, sessionManager))
-	r.With(authMW, requireAdmin).Get("/admin/projects/archived", handlers.AdminArchivedProjects(tpls["admin_archived_projects"], projectRepo, sessionManager))
-	r.With(authMW, requireAdmin).Post("/admin/projects/{id}/delete-forever", handlers.DeleteProjectForever(projectRepo, sessionManager))
-	r.With(authMW, requireAdmin).Post("/admin/projects/{id}/restore", handlers.RestoreProject(projectRepo, sessionManager))
+	csrf.With(authMW, canManageUsers).Get("/admin", handlers.Admin(tpls["admin"], sessionManager))
+	csrf.With(authMW, canManageUsers).Post("/admin/invites", handlers.CreateInvite(sessionManager, dbPool, magicLinks))
+	csrf.With(authMW, canManageUsers).Get("/admin/users", handlers.AdminUsers(tpls["admin_users"], userRepo, sessionManager))
+	csrf.With(authMW, canManageUsers).Post("/admin/users/{id}/role", handlers.ChangeUserRole(userRepo, userCache, sessionManager))
+	csrf.With(authMW, canManageUsers).Post("/admin/users/{id}/deactivate", handlers.DeactivateUser(userRepo, userCache, sessionManager))
+	csrf.With(authMW, canManageUsers).Post("/admin/users/{id}/reactivate", handlers.ReactivateUser(userRepo, userCache, sessionManager))
+	csrf.With(authMW, canManageUsers).Post("/admin/users/{id}/logout", handlers.LogoutUserEverywhere(userRepo, userCache, sessionManager))
+	csrf.With(authMW, canManageTags).Get("/admin/tags", handlers.AdminTags(tpls["admin_tags"], tagRepo, sessionManager))
+	csrf.With(authMW, canManageTags).Post("/admin/tags", handlers.CreateCuratedTag(tagRepo, sessionManager))
+	csrf.With(authMW, canManageTags).Post("/admin/tags/{id}/curated", handlers.SetTagCurated(tagRepo, sessionManager))
+	csrf.With(authMW, canManageTags).Post("/admin/tags/{id}/delete", handlers.DeleteTag(tagRepo, sessionManager))
+	uploadForms.Get("/projects/new", handlers.NewProject(tpls["new_project"], projectRepo, tagRepo, store, sessionManager))
+	uploadForms.Post("/projects/new", handlers.NewProject(tpls["new_project"], projectRepo, tagRepo, store, sessionManager))
+	csrf.With(authMW).Get("/me/projects", handlers.MyProjects(tpls["my_projects"], projectRepo, sessionManager))
+	uploadForms.Get("/projects/{id}/edit", handlers.EditProject(tpls["project_edit"], projectRepo, tagRepo, store, sessionManager))
+	uploadForms.Post("/projects/{id}/edit", handlers.EditProject(tpls["project_edit"], projectRepo, tagRepo, store, sessionManager))
+	csrf.With(authMW).Post("/projects/{id}/submit", handlers.SubmitDraft(projectRepo, sessionManager))
+	csrf.With(authMW).Post("/projects/preview", handlers.PreviewDescription())
+	csrf.With(authMW, canApprove).Get("/admin/projects", handlers.ListUnapprovedProjects(tpls["admin_projects"], projectRepo, sessionManager, cfg.PageSize))
+	csrf.With(authMW, canApprove).Get("/admin/projects/{id}/revisions", handlers.ProjectRevisions(tpls["admin_project_revisions"], projectRepo, sessionManager))
+	csrf.With(authMW, canApprove).Post("/admin/projects/{id}/approve", handlers.ApproveProject(projectRepo, sessionManager, cfg.Timezone))
+	csrf.With(authMW, canApprove).Post("/admin/projects/{id}/reject", handlers.RejectProject(projectRepo, sessionManager))
+	csrf.With(authMW, canArchive).Post("/admin/projects/{id}/delete", handlers.ArchiveProject(projectRepo, sessionManager))
+	csrf.With(authMW, canApprove).Post("/admin/projects/{id}/unapprove", handlers.UnapproveProject(projectRepo, sessionManager))
+	csrf.With(authMW, canArchive).Get("/admin/projects/archived", handlers.AdminArchivedProjects(tpls["admin_archived_projects"], projectRepo, sessionManager, cfg.PageSize))
+	csrf.With(authMW, canDeleteForever).Post("/admin/projects/{id}/delete-forever", handlers.DeleteProjectForever(projectRepo, store, sessionManager))
+	csrf.With(authMW, canArchive).Post("/admin/projects/{id}/restore", handlers.RestoreProject(projectRepo, sessionManager))
 
 	// inject the correct template set into each handler
-	r.Get("/", handlers.Home(tpls["home"], sessionManager))
-	r.Get("/login", handlers.Login(tpls["login"], sessionManager, dbPool, tokenStore))
-	r.Post("/login", handlers.Login(tpls["login"], sessionManager, dbPool, tokenStore))
-	r.Get("/magic-login", handlers.MagicLogin(sessionManager, dbPool, tokenStore))
-	r.Get("/about", handlers.About(tpls["about"], sessionManager))
-	r.Get("/projects", handlers.ListProjects(tpls["projects"], projectRepo, sessionManager))
-	r.Get("/projects/{id}", handlers.ProjectDetail(tpls["project_detail"], projectRepo, sessionManager))
-	r.Post("/logout", handlers.Logout(sessionManager))
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	csrf.Get("/", handlers.Home(tpls["home"], sessionManager))
+	// Throttle magic link requests. Hitting the per-email limit returns the
+	// normal "link sent" page so it reveals nothing about the account.
+	loginIPLimiter := middleware.NewRateLimiter(cfg.LoginRateLimit.PerIP, cfg.LoginRateLimit.Window)
+	loginEmailLimiter := middleware.NewRateLimiter(cfg.LoginRateLimit.PerEmail, cfg.LoginRateLimit.Window)
+	loginIPLimiter.StartCleanup(cfg.LoginRateLimit.Window)
+	loginEmailLimiter.StartCleanup(cfg.LoginRateLimit.Window)
+
+	loginHandler := handlers.Login(tpls["login"], sessionManager, dbPool, magicLinks, cfg.RegistrationOpen)
+	csrf.Get("/login", loginHandler)
+	csrf.With(
+		middleware.RateLimit("login_ip", loginIPLimiter, middleware.ClientIP, nil),
+		middleware.RateLimit("login_email", loginEmailLimiter, middleware.FormEmail, handlers.LoginLinkSent(tpls["login"], sessionManager)),
+	).Post("/login", loginHandler)
+
+	// Open registration shares the login limiters: both mint magic links.
+	if cfg.RegistrationOpen {
+		registerHandler := handlers.Register(tpls["register"], sessionManager, dbPool, magicLinks)
+		csrf.Get("/register", registerHandler)
+		csrf.With(
+			middleware.RateLimit("login_ip", loginIPLimiter, middleware.ClientIP, nil),
+			middleware.RateLimit("login_email", loginEmailLimiter, middleware.FormEmail, handlers.RegisterLinkSent(tpls["register"], sessionManager)),
+		).Post("/register", registerHandler)
 	}
-
-	if err := ensureDirs(); err != nil {
-		log.Fatal("failed to create upload dirs", err)
-	}
-
-	log.Println("Server running on :" + port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	csrf.Get("/magic-login", handlers.MagicLogin(sessionManager, dbPool, tokenStore))
+	csrf.Get("/about", handlers.About(tpls["about"], sessionManager))
+	csrf.Get("/projects", handlers.ListProjects(tpls["projects"], projectRepo, tagRepo, sessionManager, cfg.PageSize))
+	csrf.Get("/tags", handlers.Tags(tpls["tags"], tagRepo, sessionManager))
+	csrf.Get("/tags/{slug}", handlers.ListProjects(tpls["projects"], projectRepo, tagRepo, sessionManager, cfg.PageSize))
+	csrf.Get("/projects/{id}", handlers.ProjectDetail(tpls["project_detail"], projectRepo, sessionManager))
+	csrf.Post("/logout", handlers.Logout(sessionManager))
+
+	log.Println("Server running on :" + cfg.Port)
+	log.Fatal(http.ListenAndServe(":"+cfg.Port, r))
 
 }
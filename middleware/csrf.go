@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/alexedwards/scs/v2"
+
+	"github.com/janphilippgutt/casproject/internal/auth"
+)
+
+const (
+	// CSRFFieldName is the hidden form field templates must render on every POST form.
+	CSRFFieldName  = "csrf_token"
+	csrfHeader     = "X-CSRF-Token"
+	csrfSessionKey = "csrf_token"
+
+	// MaxFormBytes bounds request bodies parsed while looking for the token.
+	MaxFormBytes = 10 << 20
+	// MaxUploadFormBytes is the limit for the project forms, which carry
+	// image uploads; it leaves room for a full gallery. Upload handlers rely
+	// on it since the form is parsed here first.
+	MaxUploadFormBytes = 32 << 20
+)
+
+// CSRF rejects state-changing requests that do not echo the session's token
+// (see CSRFToken) in the csrf_token form field or the X-CSRF-Token header.
+// Form bodies above maxBytes are rejected.
+func CSRF(sess *scs.SessionManager, maxBytes int64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			sent := r.Header.Get(csrfHeader)
+			if sent == "" {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+				var err error
+				if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+					err = r.ParseMultipartForm(maxBytes)
+				} else {
+					err = r.ParseForm()
+				}
+				if err != nil {
+					http.Error(w, "Could not parse form", http.StatusBadRequest)
+					return
+				}
+
+				sent = r.PostFormValue(CSRFFieldName)
+			}
+
+			token := sess.GetString(r.Context(), csrfSessionKey)
+			if token == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				reason := "invalid"
+				switch {
+				case sent == "":
+					reason = "missing"
+				case token == "":
+					reason = "not_issued"
+				}
+
+				slog.LogAttrs(
+					r.Context(),
+					slog.LevelWarn,
+					"csrf token rejected",
+					slog.String("event.category", "security"),
+					slog.String("event.type", "csrf"),
+					slog.String("event.outcome", "failure"),
+					slog.String("event.reason", reason),
+					slog.String("http.method", r.Method),
+					slog.String("url.path", r.URL.Path),
+					slog.String("user.id", sess.GetString(r.Context(), "user_email")),
+				)
+
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CSRFToken returns the session's CSRF token, issuing one if it has none
+// yet. Issuing writes the session, which for anonymous visitors creates
+// one, so only pages that render a POST form should call it, and before
+// they write any output.
+func CSRFToken(ctx context.Context, sess *scs.SessionManager) string {
+	if token := sess.GetString(ctx, csrfSessionKey); token != "" {
+		return token
+	}
+
+	token, err := auth.GenerateToken(32)
+	if err != nil {
+		slog.ErrorContext(ctx, "csrf token generation failed", "error", err)
+		return ""
+	}
+	sess.Put(ctx, csrfSessionKey, token)
+	return token
+}
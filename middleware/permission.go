@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/alexedwards/scs/v2"
+
+	"github.com/janphilippgutt/casproject/internal/auth"
+)
+
+// RequirePermission only lets requests through whose session role grants p.
+// Use after AuthRequired; authenticated users without p get 403.
+func RequirePermission(sess *scs.SessionManager, p auth.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role := sess.GetString(r.Context(), "role")
+			if !auth.HasPermission(role, p) {
+				slog.LogAttrs(
+					r.Context(),
+					slog.LevelWarn,
+					"permission denied",
+					slog.String("event.category", "security"),
+					slog.String("event.type", "authorization"),
+					slog.String("event.outcome", "failure"),
+					slog.String("permission", string(p)),
+					slog.String("user.id", sess.GetString(r.Context(), "user_email")),
+					slog.String("user.role", role),
+					slog.String("url.path", r.URL.Path),
+				)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
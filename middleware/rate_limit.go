@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimiter counts hits per key in fixed windows. State is process-local,
+// so limits apply per instance.
+type RateLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	count   int
+	resetAt time.Time
+}
+
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		window:  window,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow records a hit for key. If the limit is exceeded it returns false and
+// how long until the current window resets.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok || now.After(b.resetAt) {
+		b = &bucket{resetAt: now.Add(l.window)}
+		l.buckets[key] = b
+	}
+
+	b.count++
+	if b.count > l.limit {
+		return false, b.resetAt.Sub(now)
+	}
+
+	return true, 0
+}
+
+func (l *RateLimiter) CleanupExpired() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for key, b := range l.buckets {
+		if now.After(b.resetAt) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *RateLimiter) StartCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			l.CleanupExpired()
+		}
+	}()
+}
+
+// RateLimit applies l to requests, keyed by key(r). Requests with an empty
+// key are not counted. Over the limit, limited handles the request instead
+// of next; pass nil for a plain 429 response.
+func RateLimit(name string, l *RateLimiter, key func(*http.Request) string, limited http.Handler) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k := key(r)
+			if k == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ok, retryAfter := l.Allow(k)
+			if ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			slog.LogAttrs(
+				r.Context(),
+				slog.LevelWarn,
+				"rate limit exceeded",
+				slog.String("event.category", "auth"),
+				slog.String("event.type", "rate_limit"),
+				slog.String("event.outcome", "failure"),
+				slog.String("rate_limit.name", name),
+				slog.String("client.ip", ClientIP(r)),
+				slog.String("url.path", r.URL.Path),
+			)
+
+			if limited != nil {
+				limited.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			http.Error(w, "Too many requests, please try again later.", http.StatusTooManyRequests)
+		})
+	}
+}
+
+// ClientIP returns the host part of r.RemoteAddr. Behind a reverse proxy,
+// run chi's RealIP middleware first so RemoteAddr holds the client address.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// FormEmail keys requests by the normalized "email" form field.
+func FormEmail(r *http.Request) string {
+	return strings.ToLower(strings.TrimSpace(r.PostFormValue("email")))
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+
+	"github.com/janphilippgutt/casproject/internal/auth"
+)
+
+// RevalidateSession checks the logged-in user against the database (through
+// a short-lived cache) on every request. Sessions of deleted or deactivated
+// users are destroyed, as are sessions that logged in before the user's
+// sessions were revoked ("log out everywhere"), and role changes are copied into the session, so
+// admin changes take effect without waiting for the session to expire.
+func RevalidateSession(sess *scs.SessionManager, users *auth.UserCache) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			email := sess.GetString(ctx, "user_email")
+			if email == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, err := users.Get(ctx, email)
+			if err != nil {
+				slog.ErrorContext(ctx, "session revalidation failed", "user.id", email, "error", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			if user == nil || !user.Active() {
+				slog.LogAttrs(
+					ctx,
+					slog.LevelWarn,
+					"session ended: account no longer active",
+					slog.String("event.category", "auth"),
+					slog.String("event.type", "logout"),
+					slog.String("event.reason", "deactivated"),
+					slog.String("user.id", email),
+				)
+
+				if err := sess.Destroy(ctx); err != nil {
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					return
+				}
+
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Sessions from before logged_in_at was stored read as 1970 and
+			// count as revoked once any revocation happened.
+			if user.SessionRevoked(time.Unix(0, sess.GetInt64(ctx, "logged_in_at"))) {
+				slog.LogAttrs(
+					ctx,
+					slog.LevelInfo,
+					"session ended: sessions revoked",
+					slog.String("event.category", "auth"),
+					slog.String("event.type", "logout"),
+					slog.String("event.reason", "revoked"),
+					slog.String("user.id", email),
+				)
+
+				if err := sess.Destroy(ctx); err != nil {
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					return
+				}
+
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if role := sess.GetString(ctx, "role"); role != user.Role {
+				slog.LogAttrs(
+					ctx,
+					slog.LevelInfo,
+					"session role refreshed",
+					slog.String("event.category", "auth"),
+					slog.String("event.type", "role_change"),
+					slog.String("user.id", email),
+					slog.String("user.role", user.Role),
+					slog.String("user.previous_role", role),
+				)
+				sess.Put(ctx, "role", user.Role)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,2 @@
+ALTER TABLE login_tokens
+ADD COLUMN next_path TEXT NOT NULL DEFAULT '';
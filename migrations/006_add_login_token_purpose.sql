@@ -0,0 +1,3 @@
+ALTER TABLE login_tokens
+ADD COLUMN purpose TEXT NOT NULL DEFAULT 'login',
+ADD COLUMN role TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+ALTER TABLE projects
+ADD COLUMN edited_at TIMESTAMPTZ,
+ADD COLUMN edited_fields TEXT[] NOT NULL DEFAULT '{}';
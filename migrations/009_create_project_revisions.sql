@@ -0,0 +1,26 @@
+CREATE TABLE project_revisions (
+    id SERIAL PRIMARY KEY,
+    project_id INT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    project_description TEXT NOT NULL,
+    image_path TEXT NOT NULL,
+    author_email TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_project_revisions_project_id
+ON project_revisions(project_id, id);
+
+ALTER TABLE projects
+ADD COLUMN approved_revision_id INT REFERENCES project_revisions(id) ON DELETE SET NULL;
+
+-- Existing projects start with a single revision holding their current content.
+INSERT INTO project_revisions (project_id, title, project_description, image_path, author_email, created_at)
+SELECT id, title, project_description, image_path, author_email, created_at
+FROM projects;
+
+UPDATE projects p
+SET approved_revision_id = r.id
+FROM project_revisions r
+WHERE r.project_id = p.id
+  AND p.approved = true;
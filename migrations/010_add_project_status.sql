@@ -0,0 +1,17 @@
+ALTER TABLE projects
+ADD COLUMN status TEXT NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('draft', 'pending', 'approved', 'rejected', 'archived')),
+ADD COLUMN rejection_reason TEXT NOT NULL DEFAULT '';
+
+UPDATE projects
+SET status = CASE
+    WHEN deleted_at IS NOT NULL THEN 'archived'
+    WHEN approved THEN 'approved'
+    ELSE 'pending'
+END;
+
+ALTER TABLE projects
+DROP COLUMN approved;
+
+CREATE INDEX idx_projects_status
+ON projects(status);
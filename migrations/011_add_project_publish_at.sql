@@ -0,0 +1,6 @@
+ALTER TABLE projects
+ADD COLUMN publish_at TIMESTAMPTZ;
+
+CREATE INDEX idx_projects_publish_at
+ON projects(publish_at)
+WHERE status = 'approved';
@@ -0,0 +1,9 @@
+ALTER TABLE projects
+ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(project_description, '')), 'B')
+    ) STORED;
+
+CREATE INDEX idx_projects_search_vector
+ON projects USING GIN (search_vector);
@@ -0,0 +1,19 @@
+CREATE TABLE tags (
+    id SERIAL PRIMARY KEY,
+    slug TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    -- Curated tags are the admin-maintained categories offered on the
+    -- submission form; the others were typed in freely by authors.
+    curated BOOLEAN NOT NULL DEFAULT FALSE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE project_tags (
+    project_id INT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    tag_id INT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (project_id, tag_id)
+);
+
+CREATE INDEX idx_project_tags_tag_id
+ON project_tags(tag_id);
@@ -0,0 +1,22 @@
+CREATE TABLE project_images (
+    id SERIAL PRIMARY KEY,
+    project_id INT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    path TEXT NOT NULL,
+    alt_text TEXT NOT NULL DEFAULT '',
+    caption TEXT NOT NULL DEFAULT '',
+    -- 1-based place in the gallery; the first image is the project's cover.
+    position INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_project_images_project_id
+ON project_images(project_id, position);
+
+-- Existing single images become one-image galleries. projects.image_path
+-- stays as the cover shown on cards and in revisions; 020 lets it be NULL
+-- for projects without images.
+INSERT INTO project_images (project_id, path, position)
+SELECT id, image_path, 1
+FROM projects
+WHERE image_path IS NOT NULL
+  AND image_path <> '';
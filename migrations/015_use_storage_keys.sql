@@ -0,0 +1,14 @@
+-- Images are now addressed by storage key ("projects/<file>") instead of
+-- the URL path the local file server used ("/uploads/projects/<file>");
+-- the configured store turns keys into URLs.
+UPDATE projects
+SET image_path = substring(image_path FROM length('/uploads/') + 1)
+WHERE image_path LIKE '/uploads/%';
+
+UPDATE project_images
+SET path = substring(path FROM length('/uploads/') + 1)
+WHERE path LIKE '/uploads/%';
+
+UPDATE project_revisions
+SET image_path = substring(image_path FROM length('/uploads/') + 1)
+WHERE image_path LIKE '/uploads/%';
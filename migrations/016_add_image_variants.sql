@@ -0,0 +1,8 @@
+-- Uploads from now on are stored with resized variants (thumb, card,
+-- full). Older uploads only have their original, which pages keep loading.
+ALTER TABLE project_images
+ADD COLUMN has_variants BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Mirrors has_variants of the cover image, for the project lists.
+ALTER TABLE projects
+ADD COLUMN image_has_variants BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+-- Image requests look up the project an uploaded file belongs to.
+CREATE INDEX idx_project_images_path
+ON project_images(path);
@@ -0,0 +1,41 @@
+ALTER TABLE project_revisions
+ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+
+-- The gallery as it was when the revision was saved; project_revisions.image_path
+-- keeps the cover.
+CREATE TABLE project_revision_images (
+    revision_id INT NOT NULL REFERENCES project_revisions(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    path TEXT NOT NULL,
+    alt_text TEXT NOT NULL DEFAULT '',
+    caption TEXT NOT NULL DEFAULT '',
+    has_variants BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (revision_id, position)
+);
+
+-- Earlier revisions recorded neither. They all get the project's current
+-- tags; the latest revision, which matches the project, gets its current
+-- gallery and older ones their cover alone.
+UPDATE project_revisions r
+SET tags = ARRAY(
+    SELECT t.name
+    FROM project_tags pt
+    JOIN tags t ON t.id = pt.tag_id
+    WHERE pt.project_id = r.project_id
+    ORDER BY lower(t.name)
+);
+
+INSERT INTO project_revision_images (revision_id, position, path, alt_text, caption, has_variants)
+SELECT r.id,
+       ROW_NUMBER() OVER (PARTITION BY r.id ORDER BY i.position, i.id),
+       i.path, i.alt_text, i.caption, i.has_variants
+FROM project_revisions r
+JOIN project_images i ON i.project_id = r.project_id
+WHERE r.id = (SELECT MAX(id) FROM project_revisions WHERE project_id = r.project_id);
+
+INSERT INTO project_revision_images (revision_id, position, path, has_variants)
+SELECT r.id, 1, r.image_path,
+       COALESCE((SELECT bool_or(i.has_variants) FROM project_images i WHERE i.path = r.image_path), FALSE)
+FROM project_revisions r
+WHERE r.image_path <> ''
+  AND r.id < (SELECT MAX(id) FROM project_revisions WHERE project_id = r.project_id);
@@ -0,0 +1,6 @@
+-- The status a project had when it was archived, so restoring it puts it
+-- back there. Projects archived before this column existed restore to
+-- pending.
+ALTER TABLE projects
+ADD COLUMN archived_from TEXT
+    CHECK (archived_from IN ('draft', 'pending', 'approved', 'rejected'));
@@ -0,0 +1,7 @@
+-- Projects without images have no cover. Kept apart from 014 so databases
+-- that already applied it get the change too.
+ALTER TABLE projects ALTER COLUMN image_path DROP NOT NULL;
+
+UPDATE projects
+SET image_path = NULL
+WHERE image_path = '';
@@ -0,0 +1,4 @@
+-- Sessions that logged in before this time are ended on their next request;
+-- see middleware.RevalidateSession.
+ALTER TABLE users
+ADD COLUMN sessions_revoked_at TIMESTAMPTZ;